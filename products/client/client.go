@@ -0,0 +1,265 @@
+// Package client is the Go client of the products API v1. It retries requests rejected
+// with 429, and sends an Idempotency-Key with every create and status change, so a
+// retried request isn't applied twice. Requests failing with 5xx or without a response,
+// e.g. on a reset connection or a timeout of the HTTP client, are retried only when they
+// are idempotent or carry an Idempotency-Key, as the server may have applied them.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the version of the client, sent in the User-Agent header.
+const Version = "1.0.0"
+
+const (
+	basePath          = "/api/v1"
+	defaultMaxRetries = 3
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	adminToken string
+	apiKey     string
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	// sleep waits between retries. It returns early with the error of ctx.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with httpClient instead of http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAdminToken authorizes the requests as the admin, e.g. to change the status of products.
+func WithAdminToken(token string) Option {
+	return func(c *Client) {
+		c.adminToken = token
+	}
+}
+
+// WithAPIKey sends the key in the X-API-Key header, selecting its rate limit policy.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithRetries sets how many times a request is retried. Zero disables retries.
+func WithRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubled for every further retry up
+// to maxBackoff. A Retry-After header of the response takes precedence.
+func WithBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New creates a client of the API served at baseURL, e.g. "https://products.example.com".
+func New(baseURL string, options ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("base url %q must be absolute", baseURL)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/") + basePath
+
+	c := &Client{
+		baseURL:    parsed,
+		httpClient: http.DefaultClient,
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		sleep:      sleep,
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	return c, nil
+}
+
+// request describes a call of the API. The body is encoded once, so that it can be sent
+// again on retries.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   any
+}
+
+// do sends the request, retrying on 429 and 5xx, and decodes the response into result
+// unless result is nil. Responses with an error status are returned as *APIError.
+func (c *Client) do(ctx context.Context, req request, result any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		body, err = json.Marshal(req.body)
+		if err != nil {
+			return err
+		}
+	}
+
+	endpoint := c.baseURL.JoinPath(req.path)
+	endpoint.RawQuery = req.query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, endpoint.String(), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for name, values := range req.header {
+			httpReq.Header[name] = values
+		}
+		c.authorize(httpReq)
+		httpReq.Header.Set("Accept", "application/json")
+		httpReq.Header.Set("User-Agent", "online-store-go-products-client/"+Version)
+		if body != nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.maxRetries || !idempotent(httpReq) {
+				return err
+			}
+			if err := c.sleep(ctx, c.backoff(attempt, 0)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if resp.StatusCode < http.StatusBadRequest {
+			err := decode(resp, result)
+			_ = resp.Body.Close()
+			return err
+		}
+
+		apiErr := newAPIError(resp)
+		_ = resp.Body.Close()
+
+		if attempt >= c.maxRetries || !retryable(httpReq, resp.StatusCode) {
+			return apiErr
+		}
+		if err := c.sleep(ctx, c.backoff(attempt, apiErr.RetryAfter)); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) authorize(req *http.Request) {
+	if c.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+}
+
+func decode(resp *http.Response, result any) error {
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// idempotent reports whether sending req again is safe after it failed without a response.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retryable reports whether req may be sent again after a response with status. A 429
+// was rejected before the request was applied.
+func retryable(req *http.Request, status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError && idempotent(req)
+}
+
+// backoff returns the delay before the retry following attempt: the Retry-After of the
+// response when the server sent one, otherwise an exponential backoff with full jitter.
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	ceiling := min(c.minBackoff<<attempt, c.maxBackoff)
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return time.Duration(mathrand.Int64N(int64(ceiling)) + 1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+
+	return 0
+}
+
+// newIdempotencyKey returns a random key for the Idempotency-Key header.
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.Join(errors.New("generating an idempotency key failed"), err)
+	}
+
+	return hex.EncodeToString(key), nil
+}
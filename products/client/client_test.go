@@ -0,0 +1,297 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedServer closes the connection of the first drops requests, answers the next
+// ones with the statuses in order, then with 201 and a product.
+type scriptedServer struct {
+	mu         sync.Mutex
+	drops      int
+	statuses   []int
+	retryAfter string
+	bodies     []string
+	keys       []string
+}
+
+func (s *scriptedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	s.bodies = append(s.bodies, string(body))
+	s.keys = append(s.keys, r.Header.Get("Idempotency-Key"))
+
+	if s.drops > 0 {
+		s.drops--
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+		return
+	}
+
+	if len(s.statuses) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"id":11,"name":"Product","status":"draft","created_at":"2026-01-02T03:04:05Z"}`)
+		return
+	}
+
+	status := s.statuses[0]
+	s.statuses = s.statuses[1:]
+	if s.retryAfter != "" {
+		w.Header().Set("Retry-After", s.retryAfter)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, `{"type":"about:blank","title":"`+http.StatusText(status)+`","status":0,"detail":"scripted","instance":"req-1"}`)
+}
+
+// attempts returns the number of requests received. Dropped connections answer no
+// response to order the client after the handler, hence the lock.
+func (s *scriptedServer) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.bodies)
+}
+
+// newTestClient returns a client of the server recording its waits instead of sleeping.
+func newTestClient(t *testing.T, server http.Handler, options ...Option) (*Client, *[]time.Duration) {
+	t.Helper()
+
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	c, err := New(ts.URL, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+
+	return c, &waits
+}
+
+var testCreateProductRequest = CreateProductRequest{Name: "Product", Description: "Description", Category: "Category"}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should retry after the Retry-After of a 429", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{statuses: []int{http.StatusTooManyRequests}, retryAfter: "2"}
+		c, waits := newTestClient(t, server)
+
+		// Act
+		product, err := c.CreateProduct(ctx, testCreateProductRequest)
+
+		// Assert
+		if err != nil || product.ID != 11 {
+			t.Fatalf("expected product 11, got %v, %v", product, err)
+		}
+		if len(*waits) != 1 || (*waits)[0] != 2*time.Second {
+			t.Errorf("expected one wait of 2s, got %v", *waits)
+		}
+		if product.CreatedAt != time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) {
+			t.Errorf("expected the creation time to be decoded, got %v", product.CreatedAt)
+		}
+	})
+
+	t.Run("should resend the body with the same Idempotency-Key", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable}}
+		c, _ := newTestClient(t, server)
+
+		// Act
+		_, err := c.CreateProduct(ctx, testCreateProductRequest)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(server.bodies) != 3 || server.bodies[0] == "" || server.bodies[2] != server.bodies[0] {
+			t.Errorf("expected the same body in all 3 attempts, got %q", server.bodies)
+		}
+		if server.keys[0] == "" || server.keys[1] != server.keys[0] || server.keys[2] != server.keys[0] {
+			t.Errorf("expected the same Idempotency-Key in all attempts, got %q", server.keys)
+		}
+	})
+
+	t.Run("should back off exponentially up to the maximum", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{statuses: []int{500, 500, 500, 500}}
+		c, waits := newTestClient(t, server, WithBackoff(10*time.Millisecond, 30*time.Millisecond))
+
+		// Act
+		_, err := c.CreateProduct(ctx, testCreateProductRequest)
+
+		// Assert
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("expected a 500 API error, got %v", err)
+		}
+		ceilings := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}
+		if len(*waits) != len(ceilings) {
+			t.Fatalf("expected %d waits, got %v", len(ceilings), *waits)
+		}
+		for i, wait := range *waits {
+			if wait <= 0 || wait > ceilings[i] {
+				t.Errorf("expected wait %d within (0, %v], got %v", i, ceilings[i], wait)
+			}
+		}
+	})
+
+	t.Run("should not retry client errors", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{statuses: []int{http.StatusConflict}}
+		c, waits := newTestClient(t, server)
+
+		// Act
+		_, err := c.CreateProduct(ctx, testCreateProductRequest)
+
+		// Assert
+		if !errors.Is(err, ErrConflict) {
+			t.Errorf("expected ErrConflict, got %v", err)
+		}
+		if len(*waits) != 0 || len(server.bodies) != 1 {
+			t.Errorf("expected a single attempt, got %d", len(server.bodies))
+		}
+	})
+
+	t.Run("should retry a create when the connection drops", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{drops: 1}
+		c, waits := newTestClient(t, server)
+
+		// Act
+		product, err := c.CreateProduct(ctx, testCreateProductRequest)
+
+		// Assert
+		if err != nil || product.ID != 11 {
+			t.Fatalf("expected product 11, got %v, %v", product, err)
+		}
+		if len(*waits) != 1 || len(server.keys) != 2 || server.keys[1] != server.keys[0] {
+			t.Errorf("expected a retry with the same Idempotency-Key, got %q", server.keys)
+		}
+	})
+
+	t.Run("should not retry a request without an Idempotency-Key when the connection drops", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{drops: 1}
+		c, waits := newTestClient(t, server)
+
+		// Act
+		err := c.do(ctx, request{method: http.MethodPost, path: "/products/11/status", body: map[string]string{"status": "published"}}, nil)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected the network error")
+		}
+		if attempts := server.attempts(); len(*waits) != 0 || attempts != 1 {
+			t.Errorf("expected a single attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("should retry only the 429 of a request without an Idempotency-Key", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{statuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}}
+		c, waits := newTestClient(t, server)
+
+		// Act
+		err := c.do(ctx, request{method: http.MethodPost, path: "/products/11/status", body: map[string]string{"status": "published"}}, nil)
+
+		// Assert
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected a 503 API error, got %v", err)
+		}
+		if len(*waits) != 1 || len(server.bodies) != 2 {
+			t.Errorf("expected a single retry, got %d attempts", len(server.bodies))
+		}
+	})
+
+	t.Run("should retry a transition with the same Idempotency-Key", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{statuses: []int{http.StatusServiceUnavailable}}
+		c, _ := newTestClient(t, server)
+
+		// Act
+		_, err := c.TransitionProduct(ctx, 11, Published)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(server.keys) != 2 || server.keys[0] == "" || server.keys[1] != server.keys[0] {
+			t.Errorf("expected the same Idempotency-Key in both attempts, got %q", server.keys)
+		}
+	})
+
+	t.Run("should return the rate limit error when retries are disabled", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{statuses: []int{http.StatusTooManyRequests}, retryAfter: "3"}
+		c, _ := newTestClient(t, server, WithRetries(0))
+
+		// Act
+		_, err := c.CreateProduct(ctx, testCreateProductRequest)
+
+		// Assert
+		var apiErr *APIError
+		if !errors.Is(err, ErrRateLimited) || !errors.As(err, &apiErr) {
+			t.Fatalf("expected ErrRateLimited, got %v", err)
+		}
+		if apiErr.RetryAfter != 3*time.Second || apiErr.Detail != "scripted" || apiErr.Instance != "req-1" {
+			t.Errorf("expected the problem details and Retry-After, got %+v", apiErr)
+		}
+	})
+
+	t.Run("should stop retrying when the context is done", func(t *testing.T) {
+		// Arrange
+		server := &scriptedServer{statuses: []int{http.StatusServiceUnavailable}, retryAfter: "60"}
+		c, _ := newTestClient(t, server)
+		c.sleep = sleep
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		// Act
+		_, err := c.CreateProduct(ctx, testCreateProductRequest)
+
+		// Assert
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the deadline to be exceeded, got %v", err)
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("should parse seconds and HTTP dates", func(t *testing.T) {
+		// Arrange
+		date := now.Add(90 * time.Second).Format(http.TimeFormat)
+
+		// Act
+		seconds := parseRetryAfter("5", now)
+		until := parseRetryAfter(date, now)
+		invalid := parseRetryAfter("soon", now)
+
+		// Assert
+		if seconds != 5*time.Second || until != 90*time.Second || invalid != 0 {
+			t.Errorf("expected 5s, 90s and 0, got %v, %v and %v", seconds, until, invalid)
+		}
+	})
+}
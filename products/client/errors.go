@@ -0,0 +1,82 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const maxErrorBodyBytes = 1 << 16
+
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// APIError is a response with an error status. The fields besides StatusCode and
+// RetryAfter come from the RFC 7807 problem details of the body.
+type APIError struct {
+	StatusCode int          `json:"status"`
+	Type       string       `json:"type"`
+	Title      string       `json:"title"`
+	Detail     string       `json:"detail,omitempty"`
+	Instance   string       `json:"instance,omitempty"`
+	Errors     []FieldError `json:"errors,omitempty"`
+	// RetryAfter is the Retry-After header of the response, zero when it had none.
+	RetryAfter time.Duration `json:"-"`
+}
+
+// FieldError describes an invalid field of the request, named as in the JSON body or query.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("products api: %d %s: %s", e.StatusCode, e.Title, e.Detail)
+	}
+
+	return fmt.Sprintf("products api: %d %s", e.StatusCode, e.Title)
+}
+
+// Is reports the sentinel error matching the status code, so callers can use
+// errors.Is(err, client.ErrNotFound).
+func (e *APIError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	}
+
+	return false
+}
+
+// newAPIError reads the problem details of resp. Bodies that aren't problem details
+// leave the title as the text of the status code.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	_ = json.Unmarshal(body, apiErr)
+
+	apiErr.StatusCode = resp.StatusCode
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(resp.StatusCode)
+	}
+	apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+
+	return apiErr
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Status string
+
+const (
+	Draft     Status = "draft"
+	Review    Status = "review"
+	Published Status = "published"
+	Archived  Status = "archived"
+)
+
+type Order string
+
+const (
+	ASC  Order = "ASC"
+	DESC Order = "DESC"
+)
+
+type Product struct {
+	ID           int64                  `json:"id"`
+	Slug         string                 `json:"slug"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	Category     string                 `json:"category"`
+	Locale       string                 `json:"locale,omitempty"`
+	Translations map[string]Translation `json:"translations,omitempty"`
+	Status       Status                 `json:"status"`
+	PublishAt    *time.Time             `json:"publish_at,omitempty"`
+	UnpublishAt  *time.Time             `json:"unpublish_at,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+	Version      int64                  `json:"version"`
+}
+
+type Translation struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+}
+
+// ProductPage is a page of products. Next is the query string of the following page.
+type ProductPage struct {
+	Limit int       `json:"limit"`
+	Page  int       `json:"page"`
+	Order Order     `json:"order,omitempty"`
+	Total int       `json:"total"`
+	Data  []Product `json:"data"`
+	Next  string    `json:"next,omitempty"`
+}
+
+// ListProductsOptions filters the listed products. Zero values are left to the defaults of
+// the API: page 1 of 10 published products in ascending order.
+type ListProductsOptions struct {
+	Limit    int
+	Page     int
+	Order    Order
+	Search   string
+	Category []string
+	// Status other than published is only honoured for the admin.
+	Status []Status
+	Locale string
+}
+
+func (o ListProductsOptions) query() url.Values {
+	query := url.Values{}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Page > 0 {
+		query.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.Order != "" {
+		query.Set("order", string(o.Order))
+	}
+	if o.Search != "" {
+		query.Set("search", o.Search)
+	}
+	for _, category := range o.Category {
+		query.Add("category", category)
+	}
+	for _, status := range o.Status {
+		query.Add("status", string(status))
+	}
+	if o.Locale != "" {
+		query.Set("locale", o.Locale)
+	}
+
+	return query
+}
+
+type CreateProductRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+}
+
+type UpdateProductRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+}
+
+func (c *Client) ListProducts(ctx context.Context, options ListProductsOptions) (*ProductPage, error) {
+	var page ProductPage
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/products", query: options.query()}, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// Products iterates over the products of every page, starting at options.Page. It stops
+// after the first error.
+func (c *Client) Products(ctx context.Context, options ListProductsOptions) iter.Seq2[Product, error] {
+	return func(yield func(Product, error) bool) {
+		options := options
+		if options.Page < 1 {
+			options.Page = 1
+		}
+
+		for {
+			page, err := c.ListProducts(ctx, options)
+			if err != nil {
+				yield(Product{}, err)
+				return
+			}
+
+			for _, product := range page.Data {
+				if !yield(product, nil) {
+					return
+				}
+			}
+
+			if len(page.Data) == 0 || page.Page*page.Limit >= page.Total {
+				return
+			}
+			options.Page = page.Page + 1
+		}
+	}
+}
+
+func (c *Client) GetProduct(ctx context.Context, id int64) (*Product, error) {
+	var product Product
+	if err := c.do(ctx, request{method: http.MethodGet, path: productPath(id)}, &product); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// GetProductBySlug returns the product with the slug. Former slugs of a renamed product
+// are followed to the current one.
+func (c *Client) GetProductBySlug(ctx context.Context, slug string) (*Product, error) {
+	var product Product
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/products/by-slug/" + url.PathEscape(slug)}, &product); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// CreateProduct creates a draft product. The request carries a random Idempotency-Key,
+// so retrying it returns the product created by the first attempt.
+func (c *Client) CreateProduct(ctx context.Context, req CreateProductRequest) (*Product, error) {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var product Product
+	header := http.Header{"Idempotency-Key": {key}}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/products", header: header, body: req}, &product); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+func (c *Client) UpdateProduct(ctx context.Context, id int64, req UpdateProductRequest) (*Product, error) {
+	var product Product
+	if err := c.do(ctx, request{method: http.MethodPut, path: productPath(id), body: req}, &product); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+func (c *Client) DeleteProduct(ctx context.Context, id int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: productPath(id)}, nil)
+}
+
+// TransitionProduct moves the product to status. It requires WithAdminToken. Like
+// CreateProduct it sends a random Idempotency-Key, so a retry doesn't apply the change twice.
+func (c *Client) TransitionProduct(ctx context.Context, id int64, status Status) (*Product, error) {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var product Product
+	body := struct {
+		Status Status `json:"status"`
+	}{status}
+	header := http.Header{"Idempotency-Key": {key}}
+	if err := c.do(ctx, request{method: http.MethodPost, path: productPath(id) + "/status", header: header, body: body}, &product); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+func productPath(id int64) string {
+	return "/products/" + strconv.FormatInt(id, 10)
+}
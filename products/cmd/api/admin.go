@@ -0,0 +1,106 @@
+package main
+
+import (
+	"github.com/dawidpereira/online-store-go/shared"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+)
+
+// adminWriteTimeout leaves room for CPU profiles and execution traces, which take 30
+// seconds by default.
+const adminWriteTimeout = 2 * time.Minute
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+type Route struct {
+	Method string `json:"method"`
+	Route  string `json:"route"`
+}
+
+// mountAdmin serves the metrics and the runtime introspection endpoints of the admin
+// server. It has no authentication, so ADMIN_ADDR must only be reachable from inside the
+// network.
+func (app *application) mountAdmin(routes chi.Routes) http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Recoverer)
+
+	r.Handle("/metrics", app.metrics.Handler())
+	r.Mount("/debug", middleware.Profiler())
+	r.Get("/buildinfo", app.buildInfoHandler)
+	r.Get("/config", app.effectiveConfigHandler)
+	r.Get("/routes", app.routesHandler(routes))
+
+	return r
+}
+
+func (app *application) buildInfoHandler(w http.ResponseWriter, r *http.Request) {
+	info := BuildInfo{
+		Version:   app.config.Version,
+		GoVersion: runtime.Version(),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if err := writeJSON(w, http.StatusOK, info); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// effectiveConfigHandler returns the config in effect after the SIGHUP reloads, with
+// the secrets redacted.
+func (app *application) effectiveConfigHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := app.config
+	if effective := app.effectiveConfig.Load(); effective != nil {
+		cfg = *effective
+	}
+
+	if err := writeJSON(w, http.StatusOK, shared.RedactConfig(&cfg)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) routesHandler(routes chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var table []Route
+		err := chi.Walk(routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+			table = append(table, Route{Method: method, Route: route})
+			return nil
+		})
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		slices.SortFunc(table, func(a, b Route) int {
+			if order := strings.Compare(a.Route, b.Route); order != 0 {
+				return order
+			}
+			return strings.Compare(a.Method, b.Method)
+		})
+
+		if err := writeJSON(w, http.StatusOK, table); err != nil {
+			app.internalServerError(w, r, err)
+		}
+	}
+}
@@ -9,89 +9,253 @@ import (
 	"github.com/dawidpereira/online-store-go/shared"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/graphql-go/graphql"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
 type config struct {
-	addr        string
-	env         string
-	version     string
-	rateLimiter shared.Config
+	Addr              string        `env:"PORT" default:":8080"`
+	Env               string        `env:"ENV" default:"development"`
+	Version           string        `env:"VERSION" default:"1.0"`
+	AdminToken        string        `env:"ADMIN_TOKEN" secret:"true"`
+	SchedulerInterval time.Duration `env:"SCHEDULER_INTERVAL" default:"1m"`
+	// AdminAddr serves the metrics, pprof and runtime introspection. The admin server is
+	// off when empty.
+	AdminAddr string `env:"ADMIN_ADDR" default:"localhost:6060"`
+	// GRPCAddr serves the products over gRPC. The gRPC server is off when empty. Calls
+	// share the rate limits of the JSON API, matched by their full method name.
+	GRPCAddr string `env:"GRPC_ADDR" default:":9090"`
+	// APIKeys are the keys of partners as tenant:key entries. Validated keys select the
+	// api_key and tenant rate limit policies. They are reloaded on SIGHUP.
+	APIKeys []string `env:"API_KEYS" secret:"true"`
+	// ShutdownDelay is how long readiness fails before the server stops, so load
+	// balancers drain it first. It should exceed the interval of the readiness probe.
+	ShutdownDelay      time.Duration            `env:"SHUTDOWN_DELAY" default:"5s"`
+	HealthCheckTimeout time.Duration            `env:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+	RateLimiter        shared.Config            `envPrefix:"RATE_LIMIT_"`
+	RateLimitPolicies  string                   `env:"RATE_LIMIT_POLICIES_FILE"`
+	TrustedProxies     []string                 `env:"TRUSTED_PROXIES"`
+	ForwardedHeader    string                   `env:"FORWARDED_HEADER" default:"X-Forwarded-For"`
+	Tracing            shared.TracingConfig     `envPrefix:"TRACING_"`
+	AccessLog          shared.AccessLogConfig   `envPrefix:"ACCESS_LOG_"`
+	CacheControl       CacheControlConfig       `envPrefix:"CACHE_CONTROL_"`
+	Cache              store.CacheConfig        `envPrefix:"STORE_CACHE_"`
+	Idempotency        shared.IdempotencyConfig `envPrefix:"IDEMPOTENCY_"`
+	GraphQL            GraphQLConfig            `envPrefix:"GRAPHQL_"`
+	// The log levels, Features, CORSAllowedOrigins and the rate limiting settings are reloaded on SIGHUP.
+	Log                shared.LogConfig `envPrefix:"LOG_"`
+	Features           []string         `env:"FEATURES"`
+	CORSAllowedOrigins []string         `env:"CORS_ALLOWED_ORIGINS"`
+}
+
+// Validate rejects an ENV that doesn't name a profile, as it would have loaded no env
+// files, malformed API keys, a scheduler interval the ticker can't run with, a cache
+// that would keep nothing, and invalid log settings.
+func (cfg *config) Validate() error {
+	_, err := shared.ProfileEnvFiles(cfg.Env)
+	if _, keyErr := parseAPIKeys(cfg.APIKeys); keyErr != nil {
+		err = errors.Join(err, keyErr)
+	}
+	if cfg.SchedulerInterval <= 0 {
+		err = errors.Join(err, fmt.Errorf("SCHEDULER_INTERVAL must be positive, got %v", cfg.SchedulerInterval))
+	}
+	return errors.Join(err, cfg.Cache.Validate(), cfg.Log.Validate())
 }
 
 type application struct {
-	config      config
-	store       store.Storage
-	logger      *zap.SugaredLogger
-	rateLimiter shared.RateLimiter
+	config         config
+	store          store.Storage
+	logger         *zap.SugaredLogger
+	loggers        *shared.Loggers
+	rateLimiter    *shared.PolicyEngine
+	metrics        *shared.Metrics
+	tracerProvider trace.TracerProvider
+	clientIP       *shared.ClientIPResolver
+	health         *shared.Health
+	idempotency    *shared.Idempotency
+	graphQLSchema  graphql.Schema
+	settings       atomic.Pointer[settings]
+	// effectiveConfig is the config after the SIGHUP reloads.
+	effectiveConfig atomic.Pointer[config]
+	// loadConfig reads the config again on SIGHUP.
+	loadConfig func() (config, error)
 }
 
-func (app *application) mount() http.Handler {
+func (app *application) mount() *chi.Mux {
+	schema, err := app.newGraphQLSchema()
+	if err != nil {
+		app.logger.Fatal(err)
+	}
+	app.graphQLSchema = schema
+
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(shared.TracingMiddleware(app.tracerProvider))
+	r.Use(app.clientIP.Middleware)
+	r.Use(shared.AccessLog(app.loggers.Logger(httpComponent), app.config.AccessLog))
+	r.Use(app.metrics.Middleware)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(app.cors)
+	r.Use(app.authenticate)
 	r.Use(app.rateLimiter.RateLimiterMiddleware())
+	r.Use(app.idempotency.Middleware)
+
+	r.Handle("/livez", app.health.LivenessHandler())
+	r.Handle("/readyz", app.health.ReadinessHandler())
 
 	//Test workflow
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/healthcheck", app.healthcheckHandler)
 
-		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
+		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.Addr)
 		r.Get("/swagger/*", httpSwagger.Handler(
 			httpSwagger.URL(docsURL)))
 
+		r.Post("/graphql", app.graphQLHandler)
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.requireAdmin)
+			r.Get("/log-level", app.getLogLevelsHandler)
+			r.Put("/log-level", app.setLogLevelHandler)
+		})
+
 		r.Route("/products", func(r chi.Router) {
 			r.Get("/", app.listProductsHandler)
 			r.Get("/{id}", app.getProductHandler)
+			r.Get("/by-slug/{slug}", app.getProductBySlugHandler)
 			r.Post("/", app.createProductHandler)
 			r.Put("/{id}", app.updateProductHandler)
 			r.Delete("/{id}", app.deleteProductHandler)
 
+			r.With(app.requireAdmin).Post("/{id}/status", app.transitionProductHandler)
+			r.With(app.requireAdmin).Put("/{id}/schedule", app.scheduleProductHandler)
+
+			r.Route("/{id}/translations", func(r chi.Router) {
+				r.Get("/", app.listTranslationsHandler)
+				r.Put("/{locale}", app.putTranslationHandler)
+				r.Delete("/{locale}", app.deleteTranslationHandler)
+			})
 		})
 	})
 
 	return r
 }
 
-func (app *application) run(mux http.Handler) error {
-	docs.SwaggerInfo.Version = app.config.version
-	docs.SwaggerInfo.Host = fmt.Sprintf("localhost%s", app.config.addr)
+func (app *application) run(mux *chi.Mux) error {
+	docs.SwaggerInfo.Version = app.config.Version
+	docs.SwaggerInfo.Host = fmt.Sprintf("localhost%s", app.config.Addr)
 
 	srv := &http.Server{
-		Addr:         app.config.addr,
+		Addr:         app.config.Addr,
 		Handler:      mux,
 		WriteTimeout: time.Second * 30,
 		ReadTimeout:  time.Second * 10,
 		IdleTimeout:  time.Minute,
 	}
 
+	var adminSrv *http.Server
+	if app.config.AdminAddr != "" {
+		adminSrv = &http.Server{
+			Addr:         app.config.AdminAddr,
+			Handler:      app.mountAdmin(mux),
+			WriteTimeout: adminWriteTimeout,
+			ReadTimeout:  time.Second * 10,
+			IdleTimeout:  time.Minute,
+		}
+	}
+
+	var grpcSrv *grpc.Server
+	var grpcListener net.Listener
+	if app.config.GRPCAddr != "" {
+		var err error
+		grpcListener, err = net.Listen("tcp", app.config.GRPCAddr)
+		if err != nil {
+			return err
+		}
+		grpcSrv = app.newGRPCServer()
+	}
+
 	shutdown := make(chan error)
 
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go app.runScheduler(schedulerCtx, app.config.SchedulerInterval)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+		current := app.config
 		s := <-quit
+		for ; s == syscall.SIGHUP; s = <-quit {
+			app.logger.Infow("signal caught", "signal", s.String())
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+			reloaded, err := app.reload(current)
+			if err != nil {
+				app.logger.Errorw("config reload failed", "error", err.Error())
+				continue
+			}
+			current = reloaded
+			app.effectiveConfig.Store(&reloaded)
+		}
 
 		app.logger.Infow("signal caught", "signal", s.String())
+		app.health.Drain()
+		if app.config.ShutdownDelay > 0 {
+			app.logger.Infow("draining before shutdown", "delay", app.config.ShutdownDelay)
+			time.Sleep(app.config.ShutdownDelay)
+		}
+		stopScheduler()
 
-		shutdown <- srv.Shutdown(ctx)
+		// The in-flight requests get the full timeout, however long the drain took.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if adminSrv != nil {
+			err = errors.Join(err, adminSrv.Shutdown(ctx))
+		}
+		if grpcSrv != nil {
+			stopGRPC(ctx, grpcSrv)
+		}
+		shutdown <- err
 	}()
 
-	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env)
+	if adminSrv != nil {
+		go func() {
+			app.logger.Infow("admin server has started", "addr", app.config.AdminAddr)
+
+			// The API keeps serving without the admin server.
+			if err := adminSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Errorw("admin server failed", "addr", app.config.AdminAddr, "error", err.Error())
+			}
+		}()
+	}
+
+	if grpcSrv != nil {
+		go func() {
+			app.logger.Infow("grpc server has started", "addr", app.config.GRPCAddr)
+
+			if err := grpcSrv.Serve(grpcListener); err != nil {
+				app.logger.Errorw("grpc server failed", "addr", app.config.GRPCAddr, "error", err.Error())
+			}
+		}()
+	}
+
+	app.logger.Infow("server has started", "addr", app.config.Addr, "env", app.config.Env)
 
 	err := srv.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
@@ -103,7 +267,26 @@ func (app *application) run(mux http.Handler) error {
 		return err
 	}
 
-	app.logger.Infow("server has stopped", "addr", app.config.addr, "env", app.config.env)
+	if err := app.rateLimiter.Close(); err != nil {
+		return err
+	}
+
+	app.logger.Infow("server has stopped", "addr", app.config.Addr, "env", app.config.Env)
 
 	return nil
 }
+
+// stopGRPC waits for the running calls to finish until ctx is done, then cancels them.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+	}
+}
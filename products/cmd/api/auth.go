@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// isAdmin reports whether the request carries the configured admin bearer token.
+func (app *application) isAdmin(r *http.Request) bool {
+	return app.isAdminAuthorization(r.Header.Get("Authorization"))
+}
+
+// isAdminAuthorization reports whether an Authorization value is the admin bearer token.
+func (app *application) isAdminAuthorization(authorization string) bool {
+	if app.config.AdminToken == "" {
+		return false
+	}
+
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(app.config.AdminToken)) == 1
+}
+
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAdmin(r) {
+			app.unauthorizedError(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiKey is a configured partner key and the tenant it belongs to.
+type apiKey struct {
+	tenant string
+	key    string
+}
+
+// parseAPIKeys reads the tenant:key entries of the API_KEYS setting.
+func parseAPIKeys(entries []string) ([]apiKey, error) {
+	keys := make([]apiKey, 0, len(entries))
+	for i, entry := range entries {
+		tenant, key, ok := strings.Cut(entry, ":")
+		if !ok || tenant == "" || key == "" {
+			// The entry holds a secret, so only its position is reported.
+			return nil, fmt.Errorf("API_KEYS entry %d must be tenant:key", i+1)
+		}
+		keys = append(keys, apiKey{tenant: tenant, key: key})
+	}
+
+	return keys, nil
+}
+
+// partner returns the API key and its tenant when key is one of the configured keys,
+// parsed into the settings at startup and on reload.
+func (app *application) partner(key string) (string, string, bool) {
+	if key == "" {
+		return "", "", false
+	}
+
+	for _, candidate := range app.settings.Load().apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate.key)) == 1 {
+			return candidate.key, candidate.tenant, true
+		}
+	}
+
+	return "", "", false
+}
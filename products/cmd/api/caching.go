@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/dawidpereira/online-store-go/products/internal/store"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CacheControlConfig sets the Cache-Control header of the catalog reads, so a CDN can
+// cache them. Responses to admins are never stored by shared caches.
+type CacheControlConfig struct {
+	Product  string `env:"PRODUCT" default:"public, max-age=60"`
+	Products string `env:"PRODUCTS" default:"public, max-age=30"`
+}
+
+const adminCacheControl = "private, no-cache"
+
+// validators identify the representation of a response. An empty etag is computed from
+// the response body.
+type validators struct {
+	etag         string
+	lastModified time.Time
+}
+
+// productValidators derive the ETag from the version and the locale of the product, and
+// Last-Modified from UpdatedAt.
+func productValidators(product *store.Product) validators {
+	lastModified, _ := time.Parse(time.RFC3339, product.UpdatedAt)
+
+	return validators{
+		etag:         fmt.Sprintf(`"%d-%d-%s"`, product.ID, product.Version, product.Locale),
+		lastModified: lastModified,
+	}
+}
+
+// writeCacheable writes data with the cache validators and cacheControl, or answers 304
+// when the conditional headers of the request match.
+func (app *application) writeCacheable(w http.ResponseWriter, r *http.Request, cacheControl string, validators validators, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n')
+
+	if validators.etag == "" {
+		sum := sha256.Sum256(body)
+		validators.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+	if app.isAdmin(r) {
+		cacheControl = adminCacheControl
+	}
+
+	w.Header().Set("ETag", validators.etag)
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	if !validators.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", validators.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, validators) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(body)
+
+	return err
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when If-None-Match is absent,
+// as RFC 9110 orders them.
+func notModified(r *http.Request, validators validators) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, etag := range strings.Split(ifNoneMatch, ",") {
+			etag = strings.TrimSpace(etag)
+			if etag == "*" || strings.TrimPrefix(etag, "W/") == validators.etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" && !validators.lastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		return err == nil && !validators.lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
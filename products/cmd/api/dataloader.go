@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"github.com/dawidpereira/online-store-go/products/internal/store"
+	"sync"
+)
+
+// productLoader batches the product lookups of a GraphQL request. Resolvers register the
+// ids they need and return thunks. The executor calls the thunks after resolving all
+// fields of a level, so the first thunk loads every id registered so far with one
+// GetMany. Loaded products are kept for the rest of the request.
+type productLoader struct {
+	mu       sync.Mutex
+	storage  store.ProductStorage
+	pending  []int64
+	products map[int64]*store.Product
+	errs     map[int64]error
+}
+
+func newProductLoader(storage store.ProductStorage) *productLoader {
+	return &productLoader{
+		storage:  storage,
+		products: make(map[int64]*store.Product),
+		errs:     make(map[int64]error),
+	}
+}
+
+// load registers id for the next batch. The thunk returns nil for unknown ids.
+func (l *productLoader) load(ctx context.Context, id int64) func() (*store.Product, error) {
+	l.mu.Lock()
+	if _, loaded := l.products[id]; !loaded && l.errs[id] == nil {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (*store.Product, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.dispatch(ctx)
+
+		return l.products[id], l.errs[id]
+	}
+}
+
+// dispatch loads the pending ids. It must be called with mu held.
+func (l *productLoader) dispatch(ctx context.Context) {
+	if len(l.pending) == 0 {
+		return
+	}
+
+	ids := l.pending
+	l.pending = nil
+
+	products, err := l.storage.GetMany(ctx, ids)
+	for _, id := range ids {
+		if err != nil {
+			l.errs[id] = err
+			continue
+		}
+		l.products[id] = nil
+	}
+	for _, product := range products {
+		l.products[product.ID] = product
+	}
+}
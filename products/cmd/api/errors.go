@@ -1,29 +1,135 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dawidpereira/online-store-go/shared"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"io"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("internal server error", "path", r.URL.Path, "error", err.Error())
-	err = writeJSONError(w, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
-	if err != nil {
+// requestLogger returns the logger carrying the correlation fields of the request.
+func (app *application) requestLogger(r *http.Request) *zap.SugaredLogger {
+	return shared.LoggerFromContext(r.Context(), app.logger)
+}
+
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, problem shared.Problem) {
+	if err := shared.WriteProblem(w, r, problem); err != nil {
 		app.logger.Fatal(err)
 	}
 }
 
+func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.requestLogger(r).Errorw("internal server error", "path", r.URL.Path, "error", err.Error())
+	app.writeProblem(w, r, shared.NewProblem(http.StatusInternalServerError, "the server encountered a problem and could not process your request"))
+}
+
 func (app *application) badRequestError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("bad request error", "path", r.URL.Path, "error", err.Error())
-	err = writeJSONError(w, http.StatusBadRequest, err.Error())
-	if err != nil {
-		app.logger.Fatal(err)
-	}
+	app.requestLogger(r).Errorw("bad request error", "path", r.URL.Path, "error", err.Error())
+	problem := shared.NewProblem(http.StatusBadRequest, "")
+	problem.Detail, problem.Errors = describeBadRequest(err)
+	app.writeProblem(w, r, problem)
 }
 
 func (app *application) notFoundError(w http.ResponseWriter, r *http.Request) {
-	app.logger.Errorw("not found error", "path", r.URL.Path)
-	err := writeJSONError(w, http.StatusNotFound, "the requested resource could not be found")
-	if err != nil {
-		app.logger.Fatal(err)
+	app.requestLogger(r).Errorw("not found error", "path", r.URL.Path)
+	app.writeProblem(w, r, shared.NewProblem(http.StatusNotFound, "the requested resource could not be found"))
+}
+
+func (app *application) unauthorizedError(w http.ResponseWriter, r *http.Request) {
+	app.requestLogger(r).Warnw("unauthorized error", "path", r.URL.Path)
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.writeProblem(w, r, shared.NewProblem(http.StatusUnauthorized, "you must be authenticated to access this resource"))
+}
+
+func (app *application) conflictError(w http.ResponseWriter, r *http.Request, err error) {
+	app.requestLogger(r).Errorw("conflict error", "path", r.URL.Path, "error", err.Error())
+	app.writeProblem(w, r, shared.NewProblem(http.StatusConflict, err.Error()))
+}
+
+// describeBadRequest turns the errors of decoding, parsing and validating a request into
+// messages for clients. Errors created by the handlers are already meant for clients.
+func describeBadRequest(err error) (string, []shared.FieldError) {
+	var validationErrors validator.ValidationErrors
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
+	var numError *strconv.NumError
+	var timeError *time.ParseError
+
+	switch {
+	case errors.As(err, &validationErrors):
+		fieldErrors := make([]shared.FieldError, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			fieldErrors[i] = shared.FieldError{Field: fieldError.Field(), Message: validationMessage(fieldError)}
+		}
+		return "the request has invalid fields", fieldErrors
+	case errors.As(err, &syntaxError):
+		return fmt.Sprintf("the body contains badly-formed JSON at character %d", syntaxError.Offset), nil
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "the body contains badly-formed JSON", nil
+	case errors.As(err, &typeError):
+		if typeError.Field == "" {
+			return "the body must be a JSON object", nil
+		}
+		return "the request has invalid fields", []shared.FieldError{
+			{Field: typeError.Field, Message: fmt.Sprintf("must be a JSON %s", jsonType(typeError.Type))},
+		}
+	case errors.Is(err, io.EOF):
+		return "the body must not be empty", nil
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return "the request has invalid fields", []shared.FieldError{{Field: field, Message: "is not a known field"}}
+	case errors.As(err, &maxBytesError):
+		return fmt.Sprintf("the body must not be larger than %d bytes", maxBytesError.Limit), nil
+	case errors.As(err, &timeError):
+		return fmt.Sprintf("%q is not a valid RFC 3339 time", timeError.Value), nil
+	case errors.As(err, &numError):
+		return fmt.Sprintf("%q is not a valid number", numError.Num), nil
+	default:
+		return err.Error(), nil
+	}
+}
+
+// validationMessage describes a failed validation rule of the structs in this package and store.
+func validationMessage(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		return fmt.Sprintf("must be at most %s characters long", fieldError.Param())
+	case "gte":
+		return fmt.Sprintf("must be at least %s", fieldError.Param())
+	case "lte":
+		return fmt.Sprintf("must be at most %s", fieldError.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", strings.Join(strings.Fields(fieldError.Param()), ", "))
+	default:
+		return fmt.Sprintf("must satisfy %s", fieldError.Tag())
+	}
+}
+
+// jsonType names the JSON type decoded into t.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
 	}
 }
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dawidpereira/online-store-go/products/internal/store"
+	"github.com/go-playground/validator/v10"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"maps"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type GraphQLConfig struct {
+	// MaxComplexity rejects queries costing more, see queryComplexity. Zero disables the limit.
+	MaxComplexity int `env:"MAX_COMPLEXITY" default:"500"`
+}
+
+// defaultListLimit is the number of products listed without a limit argument.
+const defaultListLimit = 10
+
+type GraphQLRequest struct {
+	Query         string         `json:"query" validate:"required"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    map[string]any `json:"extensions"`
+}
+
+type graphQLContextKey struct{}
+
+// graphQLContext carries the HTTP request, for the admin check and the locales, and the
+// loader of a GraphQL request to the resolvers.
+type graphQLContext struct {
+	r      *http.Request
+	loader *productLoader
+}
+
+func graphQLContextFrom(ctx context.Context) *graphQLContext {
+	return ctx.Value(graphQLContextKey{}).(*graphQLContext)
+}
+
+// graphQLError carries a code and the invalid fields to the extensions of a GraphQL error.
+type graphQLError struct {
+	message    string
+	extensions map[string]any
+}
+
+func (e *graphQLError) Error() string {
+	return e.message
+}
+
+func (e *graphQLError) Extensions() map[string]any {
+	return e.extensions
+}
+
+// graphQLErrorFrom maps errors to GraphQL errors with the messages the JSON API uses.
+func (app *application) graphQLErrorFrom(ctx context.Context, err error) error {
+	var validationErrors validator.ValidationErrors
+	var numError *strconv.NumError
+	var productNotFoundError *store.ProductNotFoundError
+
+	switch {
+	case errors.As(err, &validationErrors), errors.As(err, &numError):
+		detail, fieldErrors := describeBadRequest(err)
+		extensions := map[string]any{"code": "BAD_USER_INPUT"}
+		if len(fieldErrors) > 0 {
+			extensions["fields"] = fieldErrors
+		}
+		return &graphQLError{message: detail, extensions: extensions}
+	case errors.As(err, &productNotFoundError):
+		return &graphQLError{message: "the requested resource could not be found", extensions: map[string]any{"code": "NOT_FOUND"}}
+	default:
+		app.requestLogger(graphQLContextFrom(ctx).r).Errorw("internal server error", "error", err.Error())
+		return &graphQLError{message: "the server encountered a problem and could not process your request", extensions: map[string]any{"code": "INTERNAL"}}
+	}
+}
+
+func (app *application) newGraphQLSchema() (graphql.Schema, error) {
+	productType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id":          productField(graphql.NewNonNull(graphql.ID), func(p *store.Product) any { return p.ID }),
+			"slug":        productField(graphql.NewNonNull(graphql.String), func(p *store.Product) any { return p.Slug }),
+			"name":        productField(graphql.NewNonNull(graphql.String), func(p *store.Product) any { return p.Name }),
+			"description": productField(graphql.NewNonNull(graphql.String), func(p *store.Product) any { return p.Description }),
+			"category":    productField(graphql.NewNonNull(graphql.String), func(p *store.Product) any { return p.Category }),
+			"locale":      productField(graphql.String, func(p *store.Product) any { return p.Locale }),
+			"status":      productField(graphql.NewNonNull(graphql.String), func(p *store.Product) any { return string(p.Status) }),
+			"publishAt":   productField(graphql.DateTime, func(p *store.Product) any { return p.PublishAt }),
+			"unpublishAt": productField(graphql.DateTime, func(p *store.Product) any { return p.UnpublishAt }),
+			"createdAt":   productField(graphql.DateTime, func(p *store.Product) any { return parseRFC3339(p.CreatedAt) }),
+			"updatedAt":   productField(graphql.DateTime, func(p *store.Product) any { return parseRFC3339(p.UpdatedAt) }),
+			"version":     productField(graphql.NewNonNull(graphql.Int), func(p *store.Product) any { return p.Version }),
+		},
+	})
+
+	orderType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Order",
+		Values: graphql.EnumValueConfigMap{
+			"ASC":  &graphql.EnumValueConfig{Value: store.ASC},
+			"DESC": &graphql.EnumValueConfig{Value: store.DESC},
+		},
+	})
+
+	pageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ProductPage",
+		Fields: graphql.Fields{
+			"items": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(store.PaginatedResponse).Data, nil
+				},
+			},
+			"limit": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"page":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"order": &graphql.Field{Type: graphql.NewNonNull(orderType)},
+			"total": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	productInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ProductInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"description": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"category":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": &graphql.Field{
+				Type:        productType,
+				Description: "The product with the id, or null when it doesn't exist or isn't published.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: app.resolveProduct,
+			},
+			"products": &graphql.Field{
+				Type:        graphql.NewNonNull(pageType),
+				Description: "A page of the products matching the filters. Statuses other than published are only listed for admins.",
+				Args: graphql.FieldConfigArgument{
+					"search":   &graphql.ArgumentConfig{Type: graphql.String},
+					"category": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"status":   &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"order":    &graphql.ArgumentConfig{Type: orderType, DefaultValue: store.ASC},
+					"limit":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultListLimit},
+					"page":     &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+				},
+				Resolve: app.resolveProducts,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createProduct": &graphql.Field{
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(productInputType)},
+				},
+				Resolve: app.resolveCreateProduct,
+			},
+			"updateProduct": &graphql.Field{
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(productInputType)},
+				},
+				Resolve: app.resolveUpdateProduct,
+			},
+			"deleteProduct": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: app.resolveDeleteProduct,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func productField(fieldType graphql.Output, value func(product *store.Product) any) *graphql.Field {
+	return &graphql.Field{
+		Type: fieldType,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return value(p.Source.(*store.Product)), nil
+		},
+	}
+}
+
+func parseRFC3339(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+
+	return &t
+}
+
+// resolveProduct loads the product through the loader of the request, so that all
+// products of a query are fetched together.
+func (app *application) resolveProduct(p graphql.ResolveParams) (any, error) {
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	gqlCtx := graphQLContextFrom(p.Context)
+	thunk := gqlCtx.loader.load(p.Context, id)
+
+	return func() (any, error) {
+		product, err := thunk()
+		if err != nil {
+			return nil, app.graphQLErrorFrom(p.Context, err)
+		}
+		if product == nil || product.Status != store.Published && !app.isAdmin(gqlCtx.r) {
+			return nil, nil
+		}
+
+		return product.Localize(store.ParseLocales(gqlCtx.r)), nil
+	}, nil
+}
+
+func (app *application) resolveProducts(p graphql.ResolveParams) (any, error) {
+	r := graphQLContextFrom(p.Context).r
+
+	query := store.ListProductsQuery{
+		PaginatedQuery: store.PaginatedQuery{
+			Limit: p.Args["limit"].(int),
+			Page:  p.Args["page"].(int),
+			Order: p.Args["order"].(store.Order),
+		},
+		Locales: store.ParseLocales(r),
+	}
+	if search, ok := p.Args["search"].(string); ok {
+		query.Search = search
+	}
+	query.Category = argStrings(p.Args["category"])
+	for _, status := range argStrings(p.Args["status"]) {
+		query.Status = append(query.Status, store.Status(status))
+	}
+
+	if !app.isAdmin(r) || len(query.Status) == 0 {
+		query.Status = []store.Status{store.Published}
+	}
+
+	if err := Validate.Struct(query); err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	page, err := app.store.Products.List(p.Context, query)
+	if err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	return page, nil
+}
+
+func (app *application) resolveCreateProduct(p graphql.ResolveParams) (any, error) {
+	input := p.Args["input"].(map[string]any)
+	createProductRequest := CreateProductRequest{
+		Name:        input["name"].(string),
+		Description: input["description"].(string),
+		Category:    input["category"].(string),
+	}
+	if err := Validate.Struct(createProductRequest); err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	product := &store.Product{
+		Name:        createProductRequest.Name,
+		Description: createProductRequest.Description,
+		Category:    createProductRequest.Category,
+	}
+	if err := app.store.Products.Create(p.Context, product); err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	return product, nil
+}
+
+func (app *application) resolveUpdateProduct(p graphql.ResolveParams) (any, error) {
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	input := p.Args["input"].(map[string]any)
+	updateProductRequest := UpdateProductRequest{
+		Name:        input["name"].(string),
+		Description: input["description"].(string),
+		Category:    input["category"].(string),
+	}
+	if err := Validate.Struct(updateProductRequest); err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	productForm := &store.Product{
+		Name:        updateProductRequest.Name,
+		Description: updateProductRequest.Description,
+		Category:    updateProductRequest.Category,
+	}
+	product, err := app.store.Products.Update(p.Context, id, productForm)
+	if err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	return product, nil
+}
+
+func (app *application) resolveDeleteProduct(p graphql.ResolveParams) (any, error) {
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	if err := app.store.Products.Delete(p.Context, id); err != nil {
+		return nil, app.graphQLErrorFrom(p.Context, err)
+	}
+
+	return true, nil
+}
+
+func argStrings(value any) []string {
+	values, _ := value.([]any)
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+
+	return strs
+}
+
+// GraphQL godoc
+//
+//	@Summary		Query the product catalog with GraphQL
+//	@Description	Runs a GraphQL query or mutation. Each query is charged to the rate limit by its complexity: one unit per field, with the fields of a product list counted once per requested product.
+//	@Tags			graphql
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		GraphQLRequest	true	"GraphQL request"
+//	@Success		200		{object}	map[string]any
+//	@Failure		400		{object}	shared.Problem
+//	@Failure		429		{object}	shared.Problem
+//	@Router			/graphql [post]
+func (app *application) graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "graphQLHandler")
+	defer span.End()
+
+	var graphQLRequest GraphQLRequest
+	if err := readJSON(w, r, &graphQLRequest, app.logger); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(graphQLRequest); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	document, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(graphQLRequest.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		app.writeGraphQLResult(w, r, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	if validation := graphql.ValidateDocument(&app.graphQLSchema, document, nil); !validation.IsValid {
+		app.writeGraphQLResult(w, r, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	complexity := queryComplexity(document, graphQLRequest.OperationName, graphQLRequest.Variables)
+	if maxComplexity := app.config.GraphQL.MaxComplexity; maxComplexity > 0 && complexity > maxComplexity {
+		err := &graphQLError{
+			message:    fmt.Sprintf("the query complexity %d exceeds the limit of %d", complexity, maxComplexity),
+			extensions: map[string]any{"code": "COMPLEXITY_LIMIT_EXCEEDED", "complexity": complexity},
+		}
+		// Extensions are only formatted for errors wrapped in a gqlerrors.Error.
+		app.writeGraphQLResult(w, r, &graphql.Result{Errors: gqlerrors.FormatErrors(gqlerrors.NewError(err.message, nil, "", nil, nil, err))})
+		return
+	}
+
+	// The rate limiter middleware charged the request, the complexity is charged on top.
+	if !app.rateLimiter.ChargeRequest(w, r, complexity) {
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphQLContextKey{}, &graphQLContext{r: r, loader: newProductLoader(app.store.Products)})
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        app.graphQLSchema,
+		AST:           document,
+		OperationName: graphQLRequest.OperationName,
+		Args:          graphQLRequest.Variables,
+		Context:       ctx,
+	})
+
+	app.writeGraphQLResult(w, r, result)
+}
+
+func (app *application) writeGraphQLResult(w http.ResponseWriter, r *http.Request, result *graphql.Result) {
+	if err := writeJSON(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// queryComplexity counts a unit for every field of the operation. The fields of the
+// items of a products page are counted once per product requested with its limit.
+func queryComplexity(document *ast.Document, operationName string, variables map[string]any) int {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operation == nil || definition.Name != nil && definition.Name.Value == operationName {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		return 0
+	}
+
+	// Variables left out of the request take the default of their definition.
+	values := make(map[string]any, len(variables))
+	maps.Copy(values, variables)
+	for _, definition := range operation.VariableDefinitions {
+		defaultValue, ok := definition.DefaultValue.(*ast.IntValue)
+		if _, set := values[definition.Variable.Name.Value]; set || !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(defaultValue.Value); err == nil {
+			values[definition.Variable.Name.Value] = float64(n)
+		}
+	}
+
+	return selectionComplexity(operation.SelectionSet, fragments, values, 1)
+}
+
+// selectionComplexity counts the fields of selectionSet. listSize is the number of
+// products requested by the enclosing products field.
+func selectionComplexity(selectionSet *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, variables map[string]any, listSize int) int {
+	if selectionSet == nil {
+		return 0
+	}
+
+	complexity := 0
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			switch selection.Name.Value {
+			case "products":
+				limit := max(intArgument(selection.Arguments, "limit", variables, defaultListLimit), 1)
+				complexity += 1 + selectionComplexity(selection.SelectionSet, fragments, variables, limit)
+			case "items":
+				complexity += 1 + listSize*selectionComplexity(selection.SelectionSet, fragments, variables, 1)
+			default:
+				complexity += 1 + selectionComplexity(selection.SelectionSet, fragments, variables, listSize)
+			}
+		case *ast.InlineFragment:
+			complexity += selectionComplexity(selection.SelectionSet, fragments, variables, listSize)
+		case *ast.FragmentSpread:
+			// Validation rejects fragment cycles before the complexity is counted.
+			if fragment, ok := fragments[selection.Name.Value]; ok {
+				complexity += selectionComplexity(fragment.SelectionSet, fragments, variables, listSize)
+			}
+		}
+	}
+
+	return complexity
+}
+
+func intArgument(arguments []*ast.Argument, name string, variables map[string]any, fallback int) int {
+	for _, argument := range arguments {
+		if argument.Name.Value != name {
+			continue
+		}
+
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				return n
+			}
+		case *ast.Variable:
+			if n, ok := variables[value.Name.Value].(float64); ok {
+				return int(n)
+			}
+		}
+	}
+
+	return fallback
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dawidpereira/online-store-go/products/internal/store"
+	productsv1 "github.com/dawidpereira/online-store-go/products/proto/products/v1"
+	"github.com/dawidpereira/online-store-go/shared"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// productServer serves the products over gRPC with the storage, validation and
+// visibility rules of the JSON API.
+type productServer struct {
+	productsv1.UnimplementedProductServiceServer
+	app *application
+}
+
+func (app *application) newGRPCServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(app.recoverUnary, app.rateLimitUnary),
+		grpc.ChainStreamInterceptor(app.recoverStream, app.rateLimitStream),
+	)
+	productsv1.RegisterProductServiceServer(server, &productServer{app: app})
+
+	return server
+}
+
+// recoverUnary turns a panic of a call into an internal error, like middleware.Recoverer
+// does for HTTP requests.
+func (app *application) recoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = app.grpcError(info.FullMethod, fmt.Errorf("panic: %v", recovered))
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func (app *application) recoverStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = app.grpcError(info.FullMethod, fmt.Errorf("panic: %v", recovered))
+		}
+	}()
+
+	return handler(srv, stream)
+}
+
+// rateLimitUnary charges calls to the rate limit policies of the JSON API. Rules match
+// calls by their full method name, e.g. /products.v1.ProductService/*.
+func (app *application) rateLimitUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := app.chargeGRPC(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (app *application) rateLimitStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := app.chargeGRPC(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, stream)
+}
+
+// chargeGRPC rejects a call over its rate limit with ResourceExhausted and a RetryInfo
+// detail, the gRPC counterparts of 429 and Retry-After.
+func (app *application) chargeGRPC(ctx context.Context, method string) error {
+	result := app.rateLimiter.Charge(app.grpcRateLimitRequest(ctx, method), 0)
+	if result.Allowed {
+		return nil
+	}
+
+	const message = "rate limit exceeded, retry later"
+	st, err := status.New(codes.ResourceExhausted, message).WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(result.RetryAfter)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, message)
+	}
+	return st.Err()
+}
+
+// grpcRateLimitRequest describes a call as the rate limiter sees requests: a POST to the
+// full method name from the peer address, with the identities authenticate would store.
+func (app *application) grpcRateLimitRequest(ctx context.Context, method string) *http.Request {
+	if app.isGRPCAdmin(ctx) {
+		ctx = shared.WithUser(ctx, "admin")
+	}
+	if values := metadata.ValueFromIncomingContext(ctx, strings.ToLower(shared.APIKeyHeader)); len(values) > 0 {
+		if key, tenant, ok := app.partner(values[0]); ok {
+			ctx = shared.WithTenant(shared.WithAPIKey(ctx, key), tenant)
+		}
+	}
+
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, method, nil)
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+
+	return r
+}
+
+// isGRPCAdmin reports whether the call carries the admin bearer token in its authorization metadata.
+func (app *application) isGRPCAdmin(ctx context.Context) bool {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	return len(values) > 0 && app.isAdminAuthorization(values[0])
+}
+
+var errGRPCNotFound = status.Error(codes.NotFound, "the requested resource could not be found")
+
+// grpcError maps errors to gRPC statuses with the messages the JSON API uses. Invalid
+// fields are listed in a BadRequest detail. Errors answered with 409 by the JSON API
+// become FailedPrecondition, as they depend on the state of the product.
+func (app *application) grpcError(method string, err error) error {
+	var validationErrors validator.ValidationErrors
+	var productNotFoundError *store.ProductNotFoundError
+	var translationNotFoundError *store.TranslationNotFoundError
+	var slugNotFoundError *store.SlugNotFoundError
+	var invalidTransitionError *store.InvalidTransitionError
+
+	switch {
+	case errors.As(err, &validationErrors):
+		app.logger.Errorw("bad request error", "method", method, "error", err.Error())
+		detail, fieldErrors := describeBadRequest(err)
+		badRequest := &errdetails.BadRequest{}
+		for _, fieldError := range fieldErrors {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldError.Field,
+				Description: fieldError.Message,
+			})
+		}
+		st, detailsErr := status.New(codes.InvalidArgument, detail).WithDetails(badRequest)
+		if detailsErr != nil {
+			return status.Error(codes.InvalidArgument, detail)
+		}
+		return st.Err()
+	case errors.As(err, &productNotFoundError), errors.As(err, &translationNotFoundError), errors.As(err, &slugNotFoundError):
+		app.logger.Errorw("not found error", "method", method)
+		return errGRPCNotFound
+	case errors.As(err, &invalidTransitionError):
+		app.logger.Errorw("conflict error", "method", method, "error", err.Error())
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		app.logger.Errorw("internal server error", "method", method, "error", err.Error())
+		return status.Error(codes.Internal, "the server encountered a problem and could not process your request")
+	}
+}
+
+func (s *productServer) CreateProduct(ctx context.Context, req *productsv1.CreateProductRequest) (*productsv1.CreateProductResponse, error) {
+	ctx, span := s.app.tracer().Start(ctx, "CreateProduct")
+	defer span.End()
+
+	createProductRequest := CreateProductRequest{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Category:    req.GetCategory(),
+	}
+	if err := Validate.Struct(createProductRequest); err != nil {
+		return nil, s.app.grpcError(productsv1.ProductService_CreateProduct_FullMethodName, err)
+	}
+
+	product := &store.Product{
+		Name:        createProductRequest.Name,
+		Description: createProductRequest.Description,
+		Category:    createProductRequest.Category,
+	}
+	if err := s.app.store.Products.Create(ctx, product); err != nil {
+		return nil, s.app.grpcError(productsv1.ProductService_CreateProduct_FullMethodName, err)
+	}
+
+	return &productsv1.CreateProductResponse{Product: productToProto(product)}, nil
+}
+
+func (s *productServer) GetProduct(ctx context.Context, req *productsv1.GetProductRequest) (*productsv1.GetProductResponse, error) {
+	ctx, span := s.app.tracer().Start(ctx, "GetProduct")
+	defer span.End()
+
+	product, err := s.app.store.Products.Get(ctx, req.GetId())
+	if err != nil {
+		return nil, s.app.grpcError(productsv1.ProductService_GetProduct_FullMethodName, err)
+	}
+
+	if product.Status != store.Published && !s.app.isGRPCAdmin(ctx) {
+		return nil, errGRPCNotFound
+	}
+
+	product = product.Localize(store.FallbackChain(req.GetLocales()...))
+
+	return &productsv1.GetProductResponse{Product: productToProto(product)}, nil
+}
+
+func (s *productServer) ListProducts(ctx context.Context, req *productsv1.ListProductsRequest) (*productsv1.ListProductsResponse, error) {
+	ctx, span := s.app.tracer().Start(ctx, "ListProducts")
+	defer span.End()
+
+	query, err := s.listQuery(ctx, req.GetFilter())
+	if err != nil {
+		return nil, s.app.grpcError(productsv1.ProductService_ListProducts_FullMethodName, err)
+	}
+
+	page, err := s.app.store.Products.List(ctx, query)
+	if err != nil {
+		return nil, s.app.grpcError(productsv1.ProductService_ListProducts_FullMethodName, err)
+	}
+
+	products, _ := page.Data.([]*store.Product)
+
+	return &productsv1.ListProductsResponse{
+		Products: productsToProto(products),
+		Limit:    int32(page.Limit),
+		Page:     int32(page.Page),
+		Order:    orderToProto(page.Order),
+		Total:    int32(page.Total),
+	}, nil
+}
+
+func (s *productServer) StreamProducts(req *productsv1.StreamProductsRequest, stream grpc.ServerStreamingServer[productsv1.StreamProductsResponse]) error {
+	ctx, span := s.app.tracer().Start(stream.Context(), "StreamProducts")
+	defer span.End()
+
+	query, err := s.listQuery(ctx, req.GetFilter())
+	if err != nil {
+		return s.app.grpcError(productsv1.ProductService_StreamProducts_FullMethodName, err)
+	}
+
+	for {
+		page, err := s.app.store.Products.List(ctx, query)
+		if err != nil {
+			return s.app.grpcError(productsv1.ProductService_StreamProducts_FullMethodName, err)
+		}
+
+		products, _ := page.Data.([]*store.Product)
+		for _, product := range products {
+			if err := stream.Send(&productsv1.StreamProductsResponse{Product: productToProto(product)}); err != nil {
+				return err
+			}
+		}
+
+		if len(products) < query.Limit || query.Page*query.Limit >= page.Total {
+			return nil
+		}
+		query.Page++
+	}
+}
+
+func (s *productServer) UpdateProduct(ctx context.Context, req *productsv1.UpdateProductRequest) (*productsv1.UpdateProductResponse, error) {
+	ctx, span := s.app.tracer().Start(ctx, "UpdateProduct")
+	defer span.End()
+
+	updateProductRequest := UpdateProductRequest{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Category:    req.GetCategory(),
+	}
+	if err := Validate.Struct(updateProductRequest); err != nil {
+		return nil, s.app.grpcError(productsv1.ProductService_UpdateProduct_FullMethodName, err)
+	}
+
+	productForm := &store.Product{
+		Name:        updateProductRequest.Name,
+		Description: updateProductRequest.Description,
+		Category:    updateProductRequest.Category,
+	}
+	product, err := s.app.store.Products.Update(ctx, req.GetId(), productForm)
+	if err != nil {
+		return nil, s.app.grpcError(productsv1.ProductService_UpdateProduct_FullMethodName, err)
+	}
+
+	return &productsv1.UpdateProductResponse{Product: productToProto(product)}, nil
+}
+
+func (s *productServer) DeleteProduct(ctx context.Context, req *productsv1.DeleteProductRequest) (*productsv1.DeleteProductResponse, error) {
+	ctx, span := s.app.tracer().Start(ctx, "DeleteProduct")
+	defer span.End()
+
+	if err := s.app.store.Products.Delete(ctx, req.GetId()); err != nil {
+		return nil, s.app.grpcError(productsv1.ProductService_DeleteProduct_FullMethodName, err)
+	}
+
+	return &productsv1.DeleteProductResponse{}, nil
+}
+
+// listQuery applies the defaults of the JSON API to filter and validates the query.
+func (s *productServer) listQuery(ctx context.Context, filter *productsv1.ProductFilter) (store.ListProductsQuery, error) {
+	query := store.ListProductsQuery{
+		PaginatedQuery: store.PaginatedQuery{
+			Limit: int(filter.GetLimit()),
+			Page:  int(filter.GetPage()),
+			Order: store.ASC,
+		},
+		Search:   filter.GetSearch(),
+		Category: filter.GetCategory(),
+		Locales:  store.FallbackChain(filter.GetLocales()...),
+	}
+	if query.Limit == 0 {
+		query.Limit = 10
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if filter.GetOrder() == productsv1.Order_ORDER_DESC {
+		query.Order = store.DESC
+	}
+	for _, status := range filter.GetStatus() {
+		query.Status = append(query.Status, store.Status(status))
+	}
+
+	if !s.app.isGRPCAdmin(ctx) || len(query.Status) == 0 {
+		query.Status = []store.Status{store.Published}
+	}
+
+	return query, Validate.Struct(query)
+}
+
+func productToProto(product *store.Product) *productsv1.Product {
+	return &productsv1.Product{
+		Id:          product.ID,
+		Slug:        product.Slug,
+		Name:        product.Name,
+		Description: product.Description,
+		Category:    product.Category,
+		Locale:      product.Locale,
+		Status:      string(product.Status),
+		PublishAt:   timestampToProto(product.PublishAt),
+		UnpublishAt: timestampToProto(product.UnpublishAt),
+		CreatedAt:   rfc3339ToProto(product.CreatedAt),
+		UpdatedAt:   rfc3339ToProto(product.UpdatedAt),
+		Version:     product.Version,
+	}
+}
+
+func productsToProto(products []*store.Product) []*productsv1.Product {
+	converted := make([]*productsv1.Product, len(products))
+	for i, product := range products {
+		converted[i] = productToProto(product)
+	}
+
+	return converted
+}
+
+func timestampToProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+
+	return timestamppb.New(*t)
+}
+
+func rfc3339ToProto(value string) *timestamppb.Timestamp {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
+
+func orderToProto(order store.Order) productsv1.Order {
+	switch order {
+	case store.ASC:
+		return productsv1.Order_ORDER_ASC
+	case store.DESC:
+		return productsv1.Order_ORDER_DESC
+	default:
+		return productsv1.Order_ORDER_UNSPECIFIED
+	}
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"github.com/dawidpereira/online-store-go/shared"
 	"net/http"
 )
 
+// healthcheckHandler reports "available" while the server is ready. Probes should use
+// /livez and /readyz, which include the result of every check.
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	status, code := "available", http.StatusOK
+	if app.health.Ready(r.Context()).Status != shared.HealthStatusPass {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
 	data := map[string]string{
-		"status":  "available",
-		"env":     app.config.env,
-		"version": app.config.version,
+		"status":  status,
+		"env":     app.config.Env,
+		"version": app.config.Version,
 	}
 
-	if err := writeJSON(w, http.StatusOK, data); err != nil {
+	if err := writeJSON(w, code, data); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
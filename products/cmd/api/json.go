@@ -6,12 +6,22 @@ import (
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"reflect"
+	"strings"
 )
 
 var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+	// Validation errors name the fields as clients send them.
+	Validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -25,12 +35,6 @@ func writeJSON(w http.ResponseWriter, status int, data any) error {
 	return nil
 }
 
-func writeJSONError(w http.ResponseWriter, status int, message string) error {
-	data := map[string]string{"error": message}
-
-	return writeJSON(w, status, data)
-}
-
 func readJSON(w http.ResponseWriter, r *http.Request, data any, logger *zap.SugaredLogger) error {
 	if r.Body == nil {
 		logger.Fatal("request body is nil")
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"go.uber.org/zap/zapcore"
+	"net/http"
+)
+
+// Components with a log level of their own, set through LOG_COMPONENT_LEVELS or the admin API.
+const (
+	httpComponent      = "http"
+	rateLimitComponent = "ratelimit"
+	storeComponent     = "store"
+)
+
+var logComponents = []string{httpComponent, rateLimitComponent, storeComponent}
+
+type SetLogLevelRequest struct {
+	Level string `json:"level" validate:"required,oneof=debug info warn error"`
+	// Component is one of http, ratelimit and store. The default level is set when omitted.
+	Component string `json:"component" validate:"omitempty,oneof=http ratelimit store"`
+}
+
+type LogLevelsResponse struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+func (app *application) logLevels() LogLevelsResponse {
+	levels := app.loggers.Levels()
+
+	response := LogLevelsResponse{
+		Level:      levels[""].String(),
+		Components: make(map[string]string, len(logComponents)),
+	}
+	for _, component := range logComponents {
+		level, exists := levels[component]
+		if !exists {
+			level = levels[""]
+		}
+		response.Components[component] = level.String()
+	}
+
+	return response
+}
+
+// Get log levels godoc
+//
+//	@Summary		Get the log levels
+//	@Description	Get the default log level and the effective level of every component
+//	@Tags			admin
+//	@Produce		json
+//	@Security		AdminToken
+//	@Success		200	{object}	LogLevelsResponse
+//	@Failure		401	{object}	shared.Problem
+//	@Failure		500	{object}	shared.Problem
+//	@Router			/admin/log-level [get]
+func (app *application) getLogLevelsHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "getLogLevelsHandler")
+	defer span.End()
+
+	if err := writeJSON(w, http.StatusOK, app.logLevels()); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Set log level godoc
+//
+//	@Summary		Set a log level
+//	@Description	Change the default log level, or the level of a component, until the next restart or SIGHUP
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param			request	body		SetLogLevelRequest	true	"Level, e.g. debug, and optional component"
+//	@Success		200		{object}	LogLevelsResponse
+//	@Failure		400		{object}	shared.Problem
+//	@Failure		401		{object}	shared.Problem
+//	@Failure		500		{object}	shared.Problem
+//	@Router			/admin/log-level [put]
+func (app *application) setLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "setLogLevelHandler")
+	defer span.End()
+
+	var setLogLevelRequest SetLogLevelRequest
+	if err := readJSON(w, r, &setLogLevelRequest, app.logger); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(setLogLevelRequest); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	level, err := zapcore.ParseLevel(setLogLevelRequest.Level)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	app.loggers.SetLevel(setLogLevelRequest.Component, level)
+	app.requestLogger(r).Infow("log level changed",
+		"component", setLogLevelRequest.Component,
+		"level", level.String(),
+	)
+
+	if err := writeJSON(w, http.StatusOK, app.logLevels()); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -1,11 +1,11 @@
 package main
 
 import (
+	"context"
 	"github.com/dawidpereira/online-store-go/products/internal/store"
 	"github.com/dawidpereira/online-store-go/shared"
-	"github.com/lpernett/godotenv"
 	"go.uber.org/zap"
-	"time"
+	"os"
 )
 
 //	@title			Products API
@@ -17,41 +17,128 @@ import (
 //	@contact.url	https://www.linkedin.com/in/pereiradawid/
 //	@contact.email	pereiradawid@outlook.com
 
+//	@securityDefinitions.apikey	AdminToken
+//	@in							header
+//	@name						Authorization
+//	@description				Admin bearer token, e.g. "Bearer <ADMIN_TOKEN>"
+
 // @BasePath	/api/v1
 func main() {
+	// The config decides the level and encoding, so it's loaded with a bootstrap logger.
 	logger := zap.Must(zap.NewProduction()).Sugar()
-	defer func(logger *zap.SugaredLogger) {
-		_ = logger.Sync()
-	}(logger)
 
-	err := godotenv.Load(".env")
+	// ENV chooses the env files, so it's resolved from the config file, the environment
+	// and the flags first.
+	loader := shared.ConfigLoader{
+		ConfigFile: os.Getenv("CONFIG_FILE"),
+		Args:       os.Args[1:],
+		Profile:    "ENV",
+	}
+	loadConfig := func() (config, error) {
+		var cfg config
+		err := loader.Load(&cfg)
+		return cfg, err
+	}
+
+	cfg, err := loadConfig()
 	if err != nil {
 		logger.Fatal(err)
 	}
 
+	loggers, err := shared.NewLoggers(cfg.Log)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer func(loggers *shared.Loggers) {
+		_ = loggers.Sync()
+	}(loggers)
+	logger = loggers.Logger("")
+	logger.Infow("configuration loaded", "profile", cfg.Env, "config", shared.RedactConfig(&cfg))
+
+	tracerProvider, err := shared.NewTracerProvider(context.Background(), cfg.Tracing, "products", cfg.Version)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	metrics := shared.NewMetrics()
+
 	storage := store.NewStorage()
 
-	cfg := config{
-		addr:    shared.GetString("PORT", ":8080"),
-		env:     shared.GetString("ENV", "development"),
-		version: shared.GetString("VERSION", "1.0"),
-		rateLimiter: shared.Config{
-			RequestPerTimeFrame: shared.GetInt("RATE_LIMIT_MAX_REQUESTS", 100),
-			TimeFrame:           shared.GetDuration("RATE_LIMIT_WINDOW", 1*time.Minute),
-			Enabled:             shared.GetBool("RATE_LIMIT_ENABLED", false),
-		},
+	cache, err := store.NewCache(cfg.Cache)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if cache != nil {
+		defer func(cache store.Cache) {
+			_ = cache.Close()
+		}(cache)
+
+		storage, err = store.NewCachedStorage(storage, cache, metrics.Registerer(), loggers.Logger(storeComponent))
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	storage, err = store.NewInstrumentedStorage(storage, metrics.Registerer(), loggers.Logger(storeComponent))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	storage = store.NewTracedStorage(storage, tracerProvider)
+
+	policies, err := loadRateLimitPolicies(cfg.RateLimitPolicies)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	rateLimiter, err := shared.NewPolicyEngine(policies, cfg.RateLimiter, loggers.Logger(rateLimitComponent))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	rateLimiter.SetMetrics(metrics)
+
+	clientIP, err := shared.NewClientIPResolver(cfg.TrustedProxies, cfg.ForwardedHeader)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	idempotencyStore, err := shared.NewIdempotencyStore(cfg.Idempotency)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	idempotency := shared.NewIdempotency(cfg.Idempotency, idempotencyStore, loggers.Logger(httpComponent))
+	defer func(idempotency *shared.Idempotency) {
+		_ = idempotency.Close()
+	}(idempotency)
+
+	health := shared.NewHealth(cfg.HealthCheckTimeout)
+	health.Register("storage", storage)
+	if checker, ok := cache.(shared.HealthChecker); ok {
+		health.Register("cache", checker)
 	}
 
 	app := &application{
-		config:      cfg,
-		store:       storage,
-		logger:      logger,
-		rateLimiter: shared.NewFixedWindowRateLimiter(cfg.rateLimiter, logger),
+		config:         cfg,
+		store:          storage,
+		logger:         logger,
+		loggers:        loggers,
+		rateLimiter:    rateLimiter,
+		metrics:        metrics,
+		tracerProvider: tracerProvider,
+		clientIP:       clientIP,
+		health:         health,
+		idempotency:    idempotency,
+		loadConfig:     loadConfig,
 	}
+	app.settings.Store(newSettings(cfg))
 
 	mux := app.mount()
 
 	err = app.run(mux)
+
+	if shutdownErr := tracerProvider.Shutdown(context.Background()); shutdownErr != nil {
+		logger.Errorw("flushing traces failed", "error", shutdownErr.Error())
+	}
+
 	if err != nil {
 		logger.Fatal(err)
 	}
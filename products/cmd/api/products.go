@@ -5,6 +5,8 @@ import (
 	"github.com/dawidpereira/online-store-go/products/internal/store"
 	"github.com/go-chi/chi/v5"
 	"net/http"
+	"net/url"
+	"path"
 	"strconv"
 )
 
@@ -21,12 +23,18 @@ type CreateProductRequest struct {
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
-//	@Param			request	body		CreateProductRequest	true	"Product details"
-//	@Success		201		{object}	store.Product
-//	@Failure		400		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			request			body		CreateProductRequest	true	"Product details"
+//	@Param			Idempotency-Key	header		string					false	"Key replaying the response of the first request to retries"
+//	@Success		201				{object}	store.Product
+//	@Failure		400				{object}	shared.Problem
+//	@Failure		409				{object}	shared.Problem
+//	@Failure		422				{object}	shared.Problem
+//	@Failure		500				{object}	shared.Problem
 //	@Router			/products [post]
 func (app *application) createProductHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "createProductHandler")
+	defer span.End()
+
 	var createProductRequest CreateProductRequest
 	if err := readJSON(w, r, &createProductRequest, app.logger); err != nil {
 		app.badRequestError(w, r, err)
@@ -44,7 +52,7 @@ func (app *application) createProductHandler(w http.ResponseWriter, r *http.Requ
 		Category:    createProductRequest.Category,
 	}
 
-	if err := app.store.Products.Create(product); err != nil {
+	if err := app.store.Products.Create(r.Context(), product); err != nil {
 		app.internalServerError(w, r, err)
 
 		return
@@ -56,9 +64,9 @@ func (app *application) createProductHandler(w http.ResponseWriter, r *http.Requ
 }
 
 type UpdateProductRequest struct {
-	Name        string `json:"name" validator:"required,max=100"`
-	Description string `json:"description" validator:"required,max=100"`
-	Category    string `json:"category" validator:"required,max=50"`
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"required,max=100"`
+	Category    string `json:"category" validate:"required,max=50"`
 }
 
 // Update product godoc
@@ -71,15 +79,18 @@ type UpdateProductRequest struct {
 //	@Param			id		path		int						true	"Product ID"
 //	@Param			request	body		UpdateProductRequest	true	"Product details"
 //	@Success		200		{object}	store.Product
-//	@Failure		400		{object}	error
-//	@Failure		404		{object}	error
-//	@Failure		500		{object}	error
+//	@Failure		400		{object}	shared.Problem
+//	@Failure		404		{object}	shared.Problem
+//	@Failure		500		{object}	shared.Problem
 //	@Router			/products/{id} [put]
 func (app *application) updateProductHandler(w http.ResponseWriter, r *http.Request) {
-	idParam := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idParam, 10, 64)
+	r, span := app.startSpan(r, "updateProductHandler")
+	defer span.End()
+
+	id, err := readIDParam(r)
 	if err != nil {
 		app.badRequestError(w, r, err)
+		return
 	}
 
 	var updateProductRequest UpdateProductRequest
@@ -100,7 +111,7 @@ func (app *application) updateProductHandler(w http.ResponseWriter, r *http.Requ
 		Category:    updateProductRequest.Category,
 	}
 
-	product, err := app.store.Products.Update(id, productForm)
+	product, err := app.store.Products.Update(r.Context(), id, productForm)
 	if err != nil {
 		var productNotFoundError *store.ProductNotFoundError
 		if errors.As(err, &productNotFoundError) {
@@ -123,18 +134,31 @@ func (app *application) updateProductHandler(w http.ResponseWriter, r *http.Requ
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
-//	@Param			limit		query		int		false	"Limit"
-//	@Param			page		query		int		false	"Page"
-//	@Param			order		query		string	false	"Order"
-//	@Param			search		query		string	false	"Search"
-//	@Param			category	query		string	false	"Category"
-//	@Success		200			{object}	store.PaginatedResponse
-//	@Failure		500			{object}	error
+//	@Param			limit				query		int		false	"Limit"
+//	@Param			page				query		int		false	"Page"
+//	@Param			order				query		string	false	"Order"
+//	@Param			search				query		string	false	"Search"
+//	@Param			category			query		string	false	"Category"
+//	@Param			locale				query		string	false	"Locale, overrides Accept-Language"
+//	@Param			Accept-Language		header		string	false	"Preferred locales"
+//	@Param			If-None-Match		header		string	false	"ETag of a cached response"
+//	@Param			If-Modified-Since	header		string	false	"Last-Modified of a cached response"
+//	@Success		200					{object}	store.PaginatedResponse
+//	@Success		304
+//	@Failure		500	{object}	shared.Problem
 //	@Router			/products [get]
 func (app *application) listProductsHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "listProductsHandler")
+	defer span.End()
+
 	pq, err := store.ParseListProductsQuery(r)
 	if err != nil {
 		app.badRequestError(w, r, err)
+		return
+	}
+
+	if !app.isAdmin(r) || len(pq.Status) == 0 {
+		pq.Status = []store.Status{store.Published}
 	}
 
 	if err := Validate.Struct(pq); err != nil {
@@ -142,7 +166,7 @@ func (app *application) listProductsHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	products, err := app.store.Products.List(pq)
+	products, err := app.store.Products.List(r.Context(), pq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 
@@ -150,7 +174,10 @@ func (app *application) listProductsHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	products.Next = pq.GetNextURL(r)
-	if err := writeJSON(w, http.StatusOK, products); err != nil {
+	w.Header().Add("Vary", "Accept-Language")
+	// Deleting a product changes the list without changing UpdatedAt, so lists only
+	// carry an ETag.
+	if err := app.writeCacheable(w, r, app.config.CacheControl.Products, validators{}, products); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -162,19 +189,28 @@ func (app *application) listProductsHandler(w http.ResponseWriter, r *http.Reque
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Product ID"
-//	@Success		200	{object}	store.Product
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Param			id					path		int		true	"Product ID"
+//	@Param			locale				query		string	false	"Locale, overrides Accept-Language"
+//	@Param			Accept-Language		header		string	false	"Preferred locales"
+//	@Param			If-None-Match		header		string	false	"ETag of a cached response"
+//	@Param			If-Modified-Since	header		string	false	"Last-Modified of a cached response"
+//	@Success		200					{object}	store.Product
+//	@Success		304
+//	@Failure		400	{object}	shared.Problem
+//	@Failure		404	{object}	shared.Problem
+//	@Failure		500	{object}	shared.Problem
 //	@Router			/products/{id} [get]
 func (app *application) getProductHandler(w http.ResponseWriter, r *http.Request) {
-	idParam := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idParam, 10, 64)
+	r, span := app.startSpan(r, "getProductHandler")
+	defer span.End()
+
+	id, err := readIDParam(r)
 	if err != nil {
 		app.badRequestError(w, r, err)
+		return
 	}
 
-	product, err := app.store.Products.Get(id)
+	product, err := app.store.Products.Get(r.Context(), id)
 	if err != nil {
 		var notFoundErr *store.ProductNotFoundError
 		if errors.As(err, &notFoundErr) {
@@ -185,7 +221,69 @@ func (app *application) getProductHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := writeJSON(w, http.StatusOK, product); err != nil {
+	if product.Status != store.Published && !app.isAdmin(r) {
+		app.notFoundError(w, r)
+		return
+	}
+
+	product = product.Localize(store.ParseLocales(r))
+	writeContentLanguage(w, product)
+
+	if err := app.writeCacheable(w, r, app.config.CacheControl.Product, productValidators(product), product); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Get product by slug godoc
+//
+//	@Summary		Get a product by slug
+//	@Description	Get a product by its slug. Former slugs of a renamed product redirect to the current one.
+//	@Tags			products
+//	@Accept			json
+//	@Produce		json
+//	@Param			slug				path		string	true	"Product slug"
+//	@Param			locale				query		string	false	"Locale, overrides Accept-Language"
+//	@Param			Accept-Language		header		string	false	"Preferred locales"
+//	@Param			If-None-Match		header		string	false	"ETag of a cached response"
+//	@Param			If-Modified-Since	header		string	false	"Last-Modified of a cached response"
+//	@Success		200					{object}	store.Product
+//	@Success		304
+//	@Success		301
+//	@Failure		404	{object}	shared.Problem
+//	@Failure		500	{object}	shared.Problem
+//	@Router			/products/by-slug/{slug} [get]
+func (app *application) getProductBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "getProductBySlugHandler")
+	defer span.End()
+
+	slug := chi.URLParam(r, "slug")
+
+	product, err := app.store.Products.GetBySlug(r.Context(), slug)
+	if err != nil {
+		var notFoundErr *store.SlugNotFoundError
+		if errors.As(err, &notFoundErr) {
+			app.notFoundError(w, r)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if product.Status != store.Published && !app.isAdmin(r) {
+		app.notFoundError(w, r)
+		return
+	}
+
+	if product.Slug != slug {
+		location := url.URL{Path: path.Join(path.Dir(r.URL.Path), product.Slug), RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, location.String(), http.StatusMovedPermanently)
+		return
+	}
+
+	product = product.Localize(store.ParseLocales(r))
+	writeContentLanguage(w, product)
+
+	if err := app.writeCacheable(w, r, app.config.CacheControl.Product, productValidators(product), product); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -199,16 +297,19 @@ func (app *application) getProductHandler(w http.ResponseWriter, r *http.Request
 //	@Produce		json
 //	@Param			id	path	int	true	"Product ID"
 //	@Success		204
-//	@Failure		500	{object}	error
+//	@Failure		500	{object}	shared.Problem
 //	@Router			/products/{id} [delete]
 func (app *application) deleteProductHandler(w http.ResponseWriter, r *http.Request) {
-	idParam := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idParam, 10, 64)
+	r, span := app.startSpan(r, "deleteProductHandler")
+	defer span.End()
+
+	id, err := readIDParam(r)
 	if err != nil {
 		app.badRequestError(w, r, err)
+		return
 	}
 
-	if err := app.store.Products.Delete(id); err != nil {
+	if err := app.store.Products.Delete(r.Context(), id); err != nil {
 		var notFoundErr *store.ProductNotFoundError
 		if errors.As(err, &notFoundErr) {
 			app.notFoundError(w, r)
@@ -222,3 +323,15 @@ func (app *application) deleteProductHandler(w http.ResponseWriter, r *http.Requ
 		app.internalServerError(w, r, err)
 	}
 }
+
+func readIDParam(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+// writeContentLanguage sets the headers describing which translation of a product was served.
+func writeContentLanguage(w http.ResponseWriter, product *store.Product) {
+	w.Header().Add("Vary", "Accept-Language")
+	if product.Locale != "" {
+		w.Header().Set("Content-Language", product.Locale)
+	}
+}
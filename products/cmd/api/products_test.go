@@ -2,9 +2,29 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dawidpereira/online-store-go/products/client"
+	"github.com/dawidpereira/online-store-go/products/internal/store"
+	productsv1 "github.com/dawidpereira/online-store-go/products/proto/products/v1"
+	"github.com/dawidpereira/online-store-go/shared"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"maps"
 	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetProduct(t *testing.T) {
@@ -70,7 +90,26 @@ func TestListProduct(t *testing.T) {
 			t.Errorf("expected %d product, got %d", expectedCount, len(data))
 		}
 	})
-	//TODO: Test filters, pagination, and ordering
+
+	t.Run("should return the requested page", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products?limit=3&page=2", nil)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		response := decodeResponseBody(t, rr.Result())
+		data, _ := response.Data.([]interface{})
+		if response.Limit != 3 || response.Page != 2 || len(data) != 3 {
+			t.Fatalf("expected 3 products of page 2, got %d of page %d", len(data), response.Page)
+		}
+		if id := data[0].(map[string]interface{})["id"]; id != float64(4) {
+			t.Errorf("expected the page to start with product 4, got %v", id)
+		}
+	})
+	//TODO: Test filters and ordering
 }
 
 func TestCreateProduct(t *testing.T) {
@@ -176,3 +215,1710 @@ func TestDeleteProduct(t *testing.T) {
 		assertResponseCode(t, http.StatusNoContent, rr.Code)
 	})
 }
+
+func TestProductTranslations(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mount()
+
+	translation := TranslationRequest{
+		Name:        "Produkt 1",
+		Description: "Beschreibung",
+		Category:    "Kategorie",
+	}
+
+	body, err := json.Marshal(translation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, "/api/v1/products/1/translations/de", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := executeRequest(req, mux)
+	assertResponseCode(t, http.StatusOK, rr.Code)
+
+	t.Run("should fall back to the language of a regional locale", func(t *testing.T) {
+		// Arrange
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Language", "de-AT, en;q=0.5")
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var product store.Product
+		if err := json.NewDecoder(rr.Body).Decode(&product); err != nil {
+			t.Fatal(err)
+		}
+		if product.Name != translation.Name {
+			t.Errorf("expected name %q, got %q", translation.Name, product.Name)
+		}
+		if language := rr.Header().Get("Content-Language"); language != "de" {
+			t.Errorf("expected Content-Language %q, got %q", "de", language)
+		}
+	})
+
+	t.Run("should return default fields when no translation matches", func(t *testing.T) {
+		// Arrange
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/products/1?locale=fr", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		var product store.Product
+		if err := json.NewDecoder(rr.Body).Decode(&product); err != nil {
+			t.Fatal(err)
+		}
+		if product.Name != "Product 1" {
+			t.Errorf("expected name %q, got %q", "Product 1", product.Name)
+		}
+	})
+
+	t.Run("should search in the requested locale", func(t *testing.T) {
+		// Arrange
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/products?locale=de-AT&search=Produkt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		response := decodeResponseBody(t, rr.Result())
+		data, ok := response.Data.([]interface{})
+		if !ok {
+			t.Fatalf("expected a slice of interface{}, got %T", response.Data)
+		}
+		if len(data) != 1 {
+			t.Errorf("expected %d product, got %d", 1, len(data))
+		}
+	})
+
+	t.Run("should return not found when deleting a missing translation", func(t *testing.T) {
+		// Arrange
+		req, err := http.NewRequest(http.MethodDelete, "/api/v1/products/1/translations/fr", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should reject an invalid locale", func(t *testing.T) {
+		// Arrange
+		req, err := http.NewRequest(http.MethodPut, "/api/v1/products/1/translations/not_a_locale!", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestProductPublication(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mount()
+
+	createDraft := func(t *testing.T) store.Product {
+		t.Helper()
+
+		body, err := json.Marshal(CreateProductRequest{
+			Name:        "Draft product",
+			Category:    "Category",
+			Description: "Description",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/api/v1/products", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		assertResponseCode(t, http.StatusCreated, rr.Code)
+
+		var product store.Product
+		if err := json.NewDecoder(rr.Body).Decode(&product); err != nil {
+			t.Fatal(err)
+		}
+		return product
+	}
+
+	transition := func(t *testing.T, id int64, status store.Status) *httptest.ResponseRecorder {
+		t.Helper()
+
+		body, err := json.Marshal(TransitionProductRequest{Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/products/%d/status", id), bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorizeAdmin(req)
+
+		return executeRequest(req, mux)
+	}
+
+	getProduct := func(t *testing.T, id int64, admin bool) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/products/%d", id), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if admin {
+			authorizeAdmin(req)
+		}
+
+		return executeRequest(req, mux)
+	}
+
+	t.Run("should create products as drafts hidden from the public", func(t *testing.T) {
+		// Arrange
+		product := createDraft(t)
+
+		// Act
+		public := getProduct(t, product.ID, false)
+		admin := getProduct(t, product.ID, true)
+
+		// Assert
+		if product.Status != store.Draft {
+			t.Errorf("expected status %q, got %q", store.Draft, product.Status)
+		}
+		assertResponseCode(t, http.StatusNotFound, public.Code)
+		assertResponseCode(t, http.StatusOK, admin.Code)
+	})
+
+	t.Run("should hide the translations of drafts from the public", func(t *testing.T) {
+		// Arrange
+		product := createDraft(t)
+		path := fmt.Sprintf("/api/v1/products/%d/translations", product.ID)
+		publicReq := httptest.NewRequest(http.MethodGet, path, nil)
+		adminReq := httptest.NewRequest(http.MethodGet, path, nil)
+		authorizeAdmin(adminReq)
+
+		// Act
+		public := executeRequest(publicReq, mux)
+		admin := executeRequest(adminReq, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusNotFound, public.Code)
+		assertResponseCode(t, http.StatusOK, admin.Code)
+	})
+
+	t.Run("should publish a product after review", func(t *testing.T) {
+		// Arrange
+		product := createDraft(t)
+
+		// Act
+		skipped := transition(t, product.ID, store.Published)
+		reviewed := transition(t, product.ID, store.Review)
+		published := transition(t, product.ID, store.Published)
+
+		// Assert
+		assertResponseCode(t, http.StatusConflict, skipped.Code)
+		assertResponseCode(t, http.StatusOK, reviewed.Code)
+		assertResponseCode(t, http.StatusOK, published.Code)
+		assertResponseCode(t, http.StatusOK, getProduct(t, product.ID, false).Code)
+	})
+
+	t.Run("should publish a scheduled product", func(t *testing.T) {
+		// Arrange
+		product := createDraft(t)
+		assertResponseCode(t, http.StatusOK, transition(t, product.ID, store.Review).Code)
+
+		publishAt := time.Now().Add(-time.Minute)
+		body, err := json.Marshal(ScheduleProductRequest{PublishAt: &publishAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/products/%d/schedule", product.ID), bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorizeAdmin(req)
+		assertResponseCode(t, http.StatusOK, executeRequest(req, mux).Code)
+
+		// Act
+		app.applySchedule(context.Background(), time.Now())
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, getProduct(t, product.ID, false).Code)
+	})
+
+	t.Run("should reject a schedule the product would never reach", func(t *testing.T) {
+		// Arrange
+		product := createDraft(t)
+		publishAt := time.Now().Add(time.Hour)
+		unpublishAt := publishAt.Add(time.Hour)
+		schedule := func(request ScheduleProductRequest) *httptest.ResponseRecorder {
+			body, err := json.Marshal(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/products/%d/schedule", product.ID), bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			authorizeAdmin(req)
+			return executeRequest(req, mux)
+		}
+
+		// Act
+		draftPublish := schedule(ScheduleProductRequest{PublishAt: &publishAt})
+		draftUnpublish := schedule(ScheduleProductRequest{UnpublishAt: &unpublishAt})
+		assertResponseCode(t, http.StatusOK, transition(t, product.ID, store.Review).Code)
+		reviewBoth := schedule(ScheduleProductRequest{PublishAt: &publishAt, UnpublishAt: &unpublishAt})
+
+		// Assert
+		assertResponseCode(t, http.StatusConflict, draftPublish.Code)
+		assertResponseCode(t, http.StatusConflict, draftUnpublish.Code)
+		assertResponseCode(t, http.StatusOK, reviewBoth.Code)
+	})
+
+	t.Run("should require an admin token to change the status", func(t *testing.T) {
+		// Arrange
+		body, err := json.Marshal(TransitionProductRequest{Status: store.Archived})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/api/v1/products/1/status", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestProductSlugs(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mount()
+
+	save := func(t *testing.T, method, target, name string) store.Product {
+		t.Helper()
+
+		body, err := json.Marshal(CreateProductRequest{
+			Name:        name,
+			Category:    "Category",
+			Description: "Description",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(method, target, bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		var product store.Product
+		if err := json.NewDecoder(rr.Body).Decode(&product); err != nil {
+			t.Fatal(err)
+		}
+		return product
+	}
+
+	first := save(t, http.MethodPost, "/api/v1/products", "Crème Brûlée")
+	second := save(t, http.MethodPost, "/api/v1/products", "Crème Brûlée")
+
+	t.Run("should generate unique slugs from the name", func(t *testing.T) {
+		// Assert
+		if first.Slug != "creme-brulee" {
+			t.Errorf("expected slug %q, got %q", "creme-brulee", first.Slug)
+		}
+		if second.Slug != "creme-brulee-2" {
+			t.Errorf("expected slug %q, got %q", "creme-brulee-2", second.Slug)
+		}
+	})
+
+	t.Run("should return a product by slug", func(t *testing.T) {
+		// Arrange
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/products/by-slug/product-1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should redirect a former slug after a rename", func(t *testing.T) {
+		// Arrange
+		renamed := save(t, http.MethodPut, fmt.Sprintf("/api/v1/products/%d", first.ID), "Tarte Tatin")
+
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/products/by-slug/creme-brulee?locale=de", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorizeAdmin(req)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusMovedPermanently, rr.Code)
+		expected := "/api/v1/products/by-slug/" + renamed.Slug + "?locale=de"
+		if location := rr.Header().Get("Location"); location != expected {
+			t.Errorf("expected location %q, got %q", expected, location)
+		}
+	})
+
+	t.Run("should drop the number of a slug when the name no longer needs it", func(t *testing.T) {
+		// Arrange
+		product := save(t, http.MethodPost, "/api/v1/products", "Model 3")
+
+		// Act
+		renamed := save(t, http.MethodPut, fmt.Sprintf("/api/v1/products/%d", product.ID), "Model")
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/products/by-slug/model-3", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorizeAdmin(req)
+		rr := executeRequest(req, mux)
+
+		// Assert
+		if product.Slug != "model-3" || renamed.Slug != "model" {
+			t.Errorf("expected slugs %q and %q, got %q and %q", "model-3", "model", product.Slug, renamed.Slug)
+		}
+		assertResponseCode(t, http.StatusMovedPermanently, rr.Code)
+	})
+
+	t.Run("should return not found for an unknown slug", func(t *testing.T) {
+		// Arrange
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/products/by-slug/unknown", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Run("should reject a scheduler interval that isn't positive", func(t *testing.T) {
+		// Arrange
+		valid := config{Env: shared.ProfileTest, SchedulerInterval: time.Minute, Cache: store.CacheConfig{Backend: store.NoCacheBackend}}
+		zero := valid
+		zero.SchedulerInterval = 0
+		negative := valid
+		negative.SchedulerInterval = -time.Second
+
+		// Act
+		validErr, zeroErr, negativeErr := valid.Validate(), zero.Validate(), negative.Validate()
+
+		// Assert
+		if validErr != nil {
+			t.Errorf("expected no error, got %v", validErr)
+		}
+		if zeroErr == nil || negativeErr == nil {
+			t.Errorf("expected errors for a zero and a negative interval, got %v and %v", zeroErr, negativeErr)
+		}
+	})
+
+	t.Run("should reject a cache that would keep nothing", func(t *testing.T) {
+		// Arrange
+		valid := config{Env: shared.ProfileTest, SchedulerInterval: time.Minute, Cache: store.CacheConfig{Backend: store.MemoryCacheBackend, TTL: time.Second, Size: 10}}
+		noTTL, noSize, disabled := valid, valid, valid
+		noTTL.Cache.TTL = 0
+		noSize.Cache.Size = -1
+		disabled.Cache = store.CacheConfig{Backend: store.NoCacheBackend}
+
+		// Act
+		validErr, noTTLErr, noSizeErr, disabledErr := valid.Validate(), noTTL.Validate(), noSize.Validate(), disabled.Validate()
+
+		// Assert
+		if validErr != nil || disabledErr != nil {
+			t.Errorf("expected no errors, got %v and %v", validErr, disabledErr)
+		}
+		if noTTLErr == nil || noSizeErr == nil {
+			t.Errorf("expected errors for a zero TTL and a negative size, got %v and %v", noTTLErr, noSizeErr)
+		}
+	})
+}
+
+func TestConfigReload(t *testing.T) {
+	t.Run("should apply reloadable settings", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		mux := app.mount()
+		next := app.config
+		next.Log = shared.LogConfig{Level: zapcore.InfoLevel, ComponentLevels: []string{"store=debug"}}
+		next.Features = []string{"reviews"}
+		next.RateLimiter = shared.Config{RequestPerTimeFrame: 1, TimeFrame: time.Minute, Enabled: true}
+		app.loadConfig = func() (config, error) {
+			return next, nil
+		}
+
+		// Act
+		_, err := app.reload(app.config)
+		first := executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil), mux)
+		second := executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil), mux)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if level := app.loggers.Levels()[storeComponent]; level != zapcore.DebugLevel {
+			t.Errorf("expected store log level debug, got %s", level)
+		}
+		if !app.featureEnabled("reviews") {
+			t.Errorf("expected the reviews feature to be enabled")
+		}
+		assertResponseCode(t, http.StatusOK, first.Code)
+		assertResponseCode(t, http.StatusTooManyRequests, second.Code)
+	})
+
+	t.Run("should keep settings that need a restart", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		next := app.config
+		next.Addr = ":9090"
+		next.Features = []string{"reviews"}
+		app.loadConfig = func() (config, error) {
+			return next, nil
+		}
+
+		// Act
+		applied, err := app.reload(app.config)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if applied.Addr != app.config.Addr {
+			t.Errorf("expected addr %q to be kept, got %q", app.config.Addr, applied.Addr)
+		}
+		if len(applied.Features) != 1 {
+			t.Errorf("expected the features to be applied, got %v", applied.Features)
+		}
+	})
+
+	t.Run("should reload the API keys", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		next := app.config
+		next.APIKeys = []string{"acme:partner-key"}
+		app.loadConfig = func() (config, error) {
+			return next, nil
+		}
+
+		// Act
+		_, err := app.reload(app.config)
+		_, tenant, ok := app.partner("partner-key")
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !ok || tenant != "acme" {
+			t.Errorf("expected the key of tenant acme, got %q, %v", tenant, ok)
+		}
+	})
+
+	t.Run("should keep the current settings when the config is invalid", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		next := app.config
+		next.Features = []string{"reviews"}
+		next.RateLimitPolicies = "missing.json"
+		app.loadConfig = func() (config, error) {
+			return next, nil
+		}
+
+		// Act
+		_, err := app.reload(app.config)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if app.featureEnabled("reviews") {
+			t.Errorf("expected the reviews feature to stay disabled")
+		}
+	})
+
+	t.Run("should keep the rate limits when the log settings are invalid", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		mux := app.mount()
+		next := app.config
+		next.Log.ComponentLevels = []string{"store"}
+		next.RateLimiter = shared.Config{RequestPerTimeFrame: 1, TimeFrame: time.Minute, Enabled: true}
+		app.loadConfig = func() (config, error) {
+			return next, nil
+		}
+
+		// Act
+		_, err := app.reload(app.config)
+		first := executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil), mux)
+		second := executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil), mux)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		assertResponseCode(t, http.StatusOK, first.Code)
+		assertResponseCode(t, http.StatusOK, second.Code)
+	})
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.APIKeys = []string{"acme:partner-key"}
+	app.settings.Store(newSettings(app.config))
+	policies := shared.PolicyConfig{
+		Policies: []shared.Policy{
+			{Name: "anonymous", Identity: shared.IdentityIP, Limit: 5, Window: time.Minute},
+			{Name: "partners", Identity: shared.IdentityAPIKey, Limit: 50, Window: time.Minute},
+			{Name: "tenants", Identity: shared.IdentityTenant, Limit: 70, Window: time.Minute},
+		},
+		Rules: []shared.Rule{
+			{Route: "/api/v1/products", Policy: "partners"},
+			{Route: "/*", Policy: "tenants"},
+			{Route: "/*", Policy: "anonymous"},
+		},
+	}
+	if err := app.rateLimiter.Reload(policies, app.config.RateLimiter); err != nil {
+		t.Fatal(err)
+	}
+	mux := app.mount()
+
+	request := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set(shared.APIKeyHeader, key)
+		}
+		return executeRequest(req, mux)
+	}
+
+	t.Run("should apply the partner and tenant policies to configured API keys", func(t *testing.T) {
+		// Act
+		list := request("/api/v1/products", "partner-key")
+		get := request("/api/v1/products/1", "partner-key")
+
+		// Assert
+		if limit := list.Header().Get("RateLimit-Limit"); limit != "50" {
+			t.Errorf("expected the partners limit of 50, got %q", limit)
+		}
+		if limit := get.Header().Get("RateLimit-Limit"); limit != "70" {
+			t.Errorf("expected the tenants limit of 70, got %q", limit)
+		}
+	})
+
+	t.Run("should count unknown API keys as anonymous", func(t *testing.T) {
+		// Act
+		list := request("/api/v1/products", "made-up")
+		get := request("/api/v1/products/1", "another")
+
+		// Assert
+		if limit := list.Header().Get("RateLimit-Limit"); limit != "5" {
+			t.Errorf("expected the anonymous limit of 5, got %q", limit)
+		}
+		if remaining := get.Header().Get("RateLimit-Remaining"); remaining != "3" {
+			t.Errorf("expected both requests to share the client IP budget, got %q remaining", remaining)
+		}
+	})
+}
+
+func TestCORS(t *testing.T) {
+	app := newTestApplication(t)
+	app.settings.Store(newSettings(config{CORSAllowedOrigins: []string{"https://shop.example"}}))
+	mux := app.mount()
+
+	t.Run("should answer preflight requests from allowed origins", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/products/1", nil)
+		req.Header.Set("Origin", "https://shop.example")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusNoContent, rr.Code)
+		if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "https://shop.example" {
+			t.Errorf("expected the origin to be allowed, got %q", origin)
+		}
+	})
+
+	t.Run("should not allow other origins", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+		req.Header.Set("Origin", "https://evil.example")
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+			t.Errorf("expected no allowed origin, got %q", origin)
+		}
+	})
+
+	t.Run("should follow reloaded origins", func(t *testing.T) {
+		// Arrange
+		app.settings.Store(newSettings(config{CORSAllowedOrigins: []string{"https://admin.example"}}))
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+		req.Header.Set("Origin", "https://admin.example")
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "https://admin.example" {
+			t.Errorf("expected the reloaded origin to be allowed, got %q", origin)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mount()
+	admin := app.mountAdmin(mux)
+
+	t.Run("should expose request and store metrics on the admin server", func(t *testing.T) {
+		// Arrange
+		executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil), mux)
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+
+		// Act
+		rr := executeRequest(req, admin)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		body := rr.Body.String()
+		for _, metric := range []string{
+			`http_requests_total{method="GET",route="/api/v1/products/{id}",status="200"} 1`,
+			`store_operation_duration_seconds_count{method="Get"} 1`,
+			"go_goroutines",
+		} {
+			if !strings.Contains(body, metric) {
+				t.Errorf("expected metrics to contain %q", metric)
+			}
+		}
+	})
+
+	t.Run("should not expose metrics on the public server", func(t *testing.T) {
+		// Act
+		rr := executeRequest(httptest.NewRequest(http.MethodGet, "/metrics", nil), mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	app := newTestApplication(t)
+	app.tracerProvider = tracerProvider
+	app.store = store.NewTracedStorage(app.store, tracerProvider)
+	mux := app.mount()
+
+	t.Run("should trace the request through the handler and the store", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		// Act
+		executeRequest(req, mux)
+
+		// Assert
+		spans := recorder.Ended()
+		names := make(map[string]sdktrace.ReadOnlySpan)
+		for _, span := range spans {
+			names[span.Name()] = span
+			if traceID := span.SpanContext().TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("expected span %q to continue the trace, got trace %s", span.Name(), traceID)
+			}
+		}
+
+		server, handler, storage := names["GET /api/v1/products/{id}"], names["getProductHandler"], names["ProductStorage.Get"]
+		if server == nil || handler == nil || storage == nil {
+			t.Fatalf("expected server, handler and store spans, got %v", slices.Collect(maps.Keys(names)))
+		}
+		if handler.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("expected the handler span to be a child of the server span")
+		}
+		if storage.Parent().SpanID() != handler.SpanContext().SpanID() {
+			t.Errorf("expected the store span to be a child of the handler span")
+		}
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	app := newTestApplication(t)
+	loggers, err := shared.NewLoggersWithCore(core, shared.LogConfig{Level: zapcore.InfoLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.loggers = loggers
+	app.config.AccessLog = shared.AccessLogConfig{SampleRate: 1}
+	mux := app.mount()
+
+	t.Run("should log errors with the correlation fields of the request", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/products/1/status", nil)
+
+		// Act
+		executeRequest(req, mux)
+
+		// Assert
+		errorLogs := logs.FilterMessage("unauthorized error").All()
+		accessLogs := logs.FilterMessage("request completed").All()
+		if len(errorLogs) != 1 || len(accessLogs) != 1 {
+			t.Fatalf("expected an error and an access log entry, got %d and %d", len(errorLogs), len(accessLogs))
+		}
+		requestID := accessLogs[0].ContextMap()["request_id"]
+		if requestID == "" || errorLogs[0].ContextMap()["request_id"] != requestID {
+			t.Errorf("expected both entries to carry request id %v, got %v", requestID, errorLogs[0].ContextMap()["request_id"])
+		}
+		if route := accessLogs[0].ContextMap()["route"]; route != "/api/v1/products/{id}/status" {
+			t.Errorf("expected the route pattern to be logged, got %v", route)
+		}
+	})
+}
+
+func TestLogLevel(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	app := newTestApplication(t)
+	loggers, err := shared.NewLoggersWithCore(core, shared.LogConfig{Level: zapcore.InfoLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.loggers = loggers
+	storage, err := store.NewInstrumentedStorage(store.NewMockStorage(), shared.NewMetrics().Registerer(), loggers.Logger(storeComponent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.store = storage
+	mux := app.mount()
+
+	t.Run("should require the admin token", func(t *testing.T) {
+		// Arrange
+		body := bytes.NewBufferString(`{"level":"debug"}`)
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/log-level", body)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should reject unknown components", func(t *testing.T) {
+		// Arrange
+		body := bytes.NewBufferString(`{"level":"debug","component":"scheduler"}`)
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/log-level", body)
+		authorizeAdmin(req)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should change the level of a component", func(t *testing.T) {
+		// Arrange
+		body := bytes.NewBufferString(`{"level":"debug","component":"store"}`)
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/log-level", body)
+		authorizeAdmin(req)
+
+		// Act
+		rr := executeRequest(req, mux)
+		executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil), mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		var response LogLevelsResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		if response.Level != "info" || response.Components[storeComponent] != "debug" || response.Components[httpComponent] != "info" {
+			t.Errorf("expected only the store at debug level, got %+v", response)
+		}
+		if logs.FilterMessage("store operation completed").Len() != 1 {
+			t.Errorf("expected the store operation to be logged at debug level")
+		}
+	})
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("should report the storage check when ready", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		mux := app.mount()
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		var report shared.HealthReport
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		if report.Checks["storage"].Status != shared.HealthStatusPass {
+			t.Errorf("expected the storage check to pass, got %+v", report.Checks)
+		}
+	})
+
+	t.Run("should not be ready when a dependency fails", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		app.health.Register("cache", shared.HealthCheckerFunc(func(ctx context.Context) error {
+			return errors.New("connection refused")
+		}))
+		mux := app.mount()
+
+		// Act
+		ready := executeRequest(httptest.NewRequest(http.MethodGet, "/readyz", nil), mux)
+		live := executeRequest(httptest.NewRequest(http.MethodGet, "/livez", nil), mux)
+		healthcheck := executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/healthcheck", nil), mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusServiceUnavailable, ready.Code)
+		assertResponseCode(t, http.StatusOK, live.Code)
+		assertResponseCode(t, http.StatusServiceUnavailable, healthcheck.Code)
+	})
+
+	t.Run("should not be ready while draining", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		mux := app.mount()
+		app.health.Drain()
+
+		// Act
+		rr := executeRequest(httptest.NewRequest(http.MethodGet, "/readyz", nil), mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusServiceUnavailable, rr.Code)
+	})
+}
+
+func TestAdminServer(t *testing.T) {
+	app := newTestApplication(t)
+	admin := app.mountAdmin(app.mount())
+
+	t.Run("should serve pprof", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+
+		// Act
+		rr := executeRequest(req, admin)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should return the build info", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/buildinfo", nil)
+
+		// Act
+		rr := executeRequest(req, admin)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		var info BuildInfo
+		if err := json.NewDecoder(rr.Body).Decode(&info); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		if !strings.HasPrefix(info.GoVersion, "go") {
+			t.Errorf("expected the go version, got %q", info.GoVersion)
+		}
+	})
+
+	t.Run("should return the effective config without secrets", func(t *testing.T) {
+		// Arrange
+		reloaded := app.config
+		reloaded.Features = []string{"reviews"}
+		app.effectiveConfig.Store(&reloaded)
+		req := httptest.NewRequest(http.MethodGet, "/config", nil)
+
+		// Act
+		rr := executeRequest(req, admin)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		var cfg map[string]string
+		if err := json.NewDecoder(rr.Body).Decode(&cfg); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		if cfg["ADMIN_TOKEN"] == testAdminToken {
+			t.Errorf("expected the admin token to be redacted")
+		}
+		if cfg["FEATURES"] != "reviews" {
+			t.Errorf("expected the reloaded features, got %q", cfg["FEATURES"])
+		}
+	})
+
+	t.Run("should list the routes of the API", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/routes", nil)
+
+		// Act
+		rr := executeRequest(req, admin)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		var routes []Route
+		if err := json.NewDecoder(rr.Body).Decode(&routes); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		if !slices.Contains(routes, Route{Method: http.MethodPut, Route: "/api/v1/products/{id}/schedule"}) {
+			t.Errorf("expected the schedule route to be listed, got %v", routes)
+		}
+	})
+}
+
+func TestHTTPCaching(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.CacheControl = CacheControlConfig{Product: "public, max-age=60", Products: "public, max-age=30"}
+	mux := app.mount()
+
+	t.Run("should answer 304 when the ETag matches", func(t *testing.T) {
+		// Arrange
+		first := executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil), mux)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+		req.Header.Set("If-None-Match", first.Header().Get("ETag"))
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, first.Code)
+		assertResponseCode(t, http.StatusNotModified, rr.Code)
+		if rr.Body.Len() != 0 {
+			t.Errorf("expected an empty body, got %q", rr.Body.String())
+		}
+		if cacheControl := first.Header().Get("Cache-Control"); cacheControl != "public, max-age=60" {
+			t.Errorf("expected the product cache control, got %q", cacheControl)
+		}
+	})
+
+	t.Run("should answer 304 when not modified since Last-Modified", func(t *testing.T) {
+		// Arrange
+		first := executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil), mux)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+		req.Header.Set("If-Modified-Since", first.Header().Get("Last-Modified"))
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusNotModified, rr.Code)
+	})
+
+	t.Run("should change the ETag when the product changes", func(t *testing.T) {
+		// Arrange
+		first := executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products/2", nil), mux)
+		update := httptest.NewRequest(http.MethodPut, "/api/v1/products/2", bytes.NewBufferString(`{"name":"Renamed","description":"Description","category":"Category"}`))
+		executeRequest(update, mux)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/2", nil)
+		req.Header.Set("If-None-Match", first.Header().Get("ETag"))
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		if rr.Header().Get("ETag") == first.Header().Get("ETag") {
+			t.Errorf("expected a new ETag, got %q", rr.Header().Get("ETag"))
+		}
+	})
+
+	t.Run("should answer 304 for an unchanged list", func(t *testing.T) {
+		// Arrange
+		first := executeRequest(httptest.NewRequest(http.MethodGet, "/api/v1/products?limit=5", nil), mux)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products?limit=5", nil)
+		req.Header.Set("If-None-Match", first.Header().Get("ETag"))
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusNotModified, rr.Code)
+		if cacheControl := first.Header().Get("Cache-Control"); cacheControl != "public, max-age=30" {
+			t.Errorf("expected the list cache control, got %q", cacheControl)
+		}
+	})
+
+	t.Run("should keep admin responses out of shared caches", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+		authorizeAdmin(req)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		if cacheControl := rr.Header().Get("Cache-Control"); cacheControl != adminCacheControl {
+			t.Errorf("expected %q, got %q", adminCacheControl, cacheControl)
+		}
+	})
+}
+
+func TestProblemDetails(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mount()
+
+	decodeProblem := func(t *testing.T, rr *httptest.ResponseRecorder) shared.Problem {
+		t.Helper()
+
+		if contentType := rr.Header().Get("Content-Type"); contentType != "application/problem+json" {
+			t.Errorf("expected a problem+json response, got %q", contentType)
+		}
+		var problem shared.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return problem
+	}
+
+	t.Run("should hide parser messages", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/abc", nil)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusBadRequest, rr.Code)
+		problem := decodeProblem(t, rr)
+		if problem.Detail != `"abc" is not a valid number` {
+			t.Errorf("expected a readable detail, got %q", problem.Detail)
+		}
+		if problem.Title != "Bad Request" || problem.Status != http.StatusBadRequest || problem.Instance == "" {
+			t.Errorf("expected the title, status and request id, got %+v", problem)
+		}
+	})
+
+	t.Run("should list the invalid fields by their json names", func(t *testing.T) {
+		// Arrange
+		body := bytes.NewBufferString(`{"name":"","description":"Description","category":"` + strings.Repeat("c", 51) + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/products", body)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusBadRequest, rr.Code)
+		problem := decodeProblem(t, rr)
+		expected := []shared.FieldError{
+			{Field: "name", Message: "is required"},
+			{Field: "category", Message: "must be at most 50 characters long"},
+		}
+		if !slices.Equal(problem.Errors, expected) {
+			t.Errorf("expected %v, got %v", expected, problem.Errors)
+		}
+	})
+
+	t.Run("should list the invalid fields of an update", func(t *testing.T) {
+		// Arrange
+		body := bytes.NewBufferString(`{"name":"","description":"Description","category":"Category"}`)
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/products/1", body)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusBadRequest, rr.Code)
+		problem := decodeProblem(t, rr)
+		expected := []shared.FieldError{{Field: "name", Message: "is required"}}
+		if !slices.Equal(problem.Errors, expected) {
+			t.Errorf("expected %v, got %v", expected, problem.Errors)
+		}
+	})
+
+	t.Run("should name unknown and mistyped fields", func(t *testing.T) {
+		// Arrange
+		unknown := httptest.NewRequest(http.MethodPost, "/api/v1/products", bytes.NewBufferString(`{"price":1}`))
+		mistyped := httptest.NewRequest(http.MethodPost, "/api/v1/products", bytes.NewBufferString(`{"name":1}`))
+
+		// Act
+		unknownProblem := decodeProblem(t, executeRequest(unknown, mux))
+		mistypedProblem := decodeProblem(t, executeRequest(mistyped, mux))
+
+		// Assert
+		if !slices.Equal(unknownProblem.Errors, []shared.FieldError{{Field: "price", Message: "is not a known field"}}) {
+			t.Errorf("expected the unknown field, got %v", unknownProblem.Errors)
+		}
+		if !slices.Equal(mistypedProblem.Errors, []shared.FieldError{{Field: "name", Message: "must be a JSON string"}}) {
+			t.Errorf("expected the mistyped field, got %v", mistypedProblem.Errors)
+		}
+	})
+
+	t.Run("should describe missing resources", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/999", nil)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusNotFound, rr.Code)
+		if problem := decodeProblem(t, rr); problem.Type != "about:blank" || problem.Title != "Not Found" {
+			t.Errorf("expected a not found problem, got %+v", problem)
+		}
+	})
+}
+
+func TestIdempotency(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mount()
+
+	newCreateRequest := func(key, name string) *http.Request {
+		body := bytes.NewBufferString(`{"name":"` + name + `","description":"Description","category":"Category"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/products", body)
+		req.Header.Set("Idempotency-Key", key)
+		return req
+	}
+
+	t.Run("should create one product for retried requests", func(t *testing.T) {
+		// Arrange
+		first := executeRequest(newCreateRequest("create-1", "Retried"), mux)
+
+		// Act
+		retry := executeRequest(newCreateRequest("create-1", "Retried"), mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusCreated, first.Code)
+		assertResponseCode(t, http.StatusCreated, retry.Code)
+		if retry.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("expected the retry to be replayed")
+		}
+		if first.Body.String() != retry.Body.String() {
+			t.Errorf("expected the same product, got %s and %s", first.Body.String(), retry.Body.String())
+		}
+	})
+
+	t.Run("should reject a key reused with a different payload", func(t *testing.T) {
+		// Arrange
+		executeRequest(newCreateRequest("create-2", "First"), mux)
+
+		// Act
+		rr := executeRequest(newCreateRequest("create-2", "Second"), mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusUnprocessableEntity, rr.Code)
+	})
+
+	t.Run("should not share keys between clients", func(t *testing.T) {
+		// Arrange
+		executeRequest(newCreateRequest("create-3", "Anonymous"), mux)
+		req := newCreateRequest("create-3", "Anonymous")
+		authorizeAdmin(req)
+
+		// Act
+		rr := executeRequest(req, mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusCreated, rr.Code)
+		if rr.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("expected the admin request to create a product")
+		}
+	})
+}
+
+func TestGRPC(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should create, update and delete a product", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		client := newTestGRPCClient(t, app)
+
+		// Act
+		created, createErr := client.CreateProduct(ctx, &productsv1.CreateProductRequest{Name: "Lamp", Description: "Description", Category: "Home"})
+		updated, updateErr := client.UpdateProduct(ctx, &productsv1.UpdateProductRequest{Id: created.GetProduct().GetId(), Name: "Desk lamp", Description: "Description", Category: "Home"})
+		_, deleteErr := client.DeleteProduct(ctx, &productsv1.DeleteProductRequest{Id: created.GetProduct().GetId()})
+		_, getErr := client.GetProduct(authorizeGRPCAdmin(ctx), &productsv1.GetProductRequest{Id: created.GetProduct().GetId()})
+
+		// Assert
+		if createErr != nil || updateErr != nil || deleteErr != nil {
+			t.Fatalf("expected no errors, got %v, %v and %v", createErr, updateErr, deleteErr)
+		}
+		if product := created.GetProduct(); product.GetStatus() != "draft" || product.GetCreatedAt() == nil {
+			t.Errorf("expected a draft product, got %v", product)
+		}
+		if product := updated.GetProduct(); product.GetName() != "Desk lamp" || product.GetVersion() != 2 {
+			t.Errorf("expected the updated product, got %v", product)
+		}
+		if code := status.Code(getErr); code != codes.NotFound {
+			t.Errorf("expected NotFound after the delete, got %v", code)
+		}
+	})
+
+	t.Run("should hide unpublished products from non-admins", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		client := newTestGRPCClient(t, app)
+		created, _ := client.CreateProduct(ctx, &productsv1.CreateProductRequest{Name: "Draft", Description: "Description", Category: "Home"})
+
+		// Act
+		_, anonymousErr := client.GetProduct(ctx, &productsv1.GetProductRequest{Id: created.GetProduct().GetId()})
+		product, adminErr := client.GetProduct(authorizeGRPCAdmin(ctx), &productsv1.GetProductRequest{Id: created.GetProduct().GetId()})
+
+		// Assert
+		if code := status.Code(anonymousErr); code != codes.NotFound {
+			t.Errorf("expected NotFound, got %v", code)
+		}
+		if adminErr != nil || product.GetProduct().GetName() != "Draft" {
+			t.Errorf("expected the draft for the admin, got %v, %v", product, adminErr)
+		}
+	})
+
+	t.Run("should report invalid fields", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		client := newTestGRPCClient(t, app)
+
+		// Act
+		_, err := client.CreateProduct(ctx, &productsv1.CreateProductRequest{Description: "Description", Category: "Home"})
+
+		// Assert
+		st := status.Convert(err)
+		if st.Code() != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, got %v", st.Code())
+		}
+		details := st.Details()
+		badRequest, ok := details[0].(*errdetails.BadRequest)
+		if len(details) != 1 || !ok {
+			t.Fatalf("expected a BadRequest detail, got %v", details)
+		}
+		if violation := badRequest.GetFieldViolations()[0]; violation.GetField() != "name" || violation.GetDescription() != "is required" {
+			t.Errorf("expected the missing name, got %v", violation)
+		}
+	})
+
+	t.Run("should report invalid fields of an update", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		client := newTestGRPCClient(t, app)
+
+		// Act
+		_, err := client.UpdateProduct(ctx, &productsv1.UpdateProductRequest{Id: 1, Name: strings.Repeat("n", 101), Description: "Description", Category: "Home"})
+
+		// Assert
+		st := status.Convert(err)
+		if st.Code() != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, got %v", st.Code())
+		}
+		if product, _ := app.store.Products.Get(ctx, 1); product.Name == strings.Repeat("n", 101) {
+			t.Errorf("expected the product to be unchanged, got %q", product.Name)
+		}
+	})
+
+	t.Run("should list a page of products", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		client := newTestGRPCClient(t, app)
+
+		// Act
+		page, err := client.ListProducts(ctx, &productsv1.ListProductsRequest{Filter: &productsv1.ProductFilter{Limit: 3, Page: 2, Order: productsv1.Order_ORDER_DESC}})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(page.GetProducts()) != 3 || page.GetTotal() != 10 || page.GetProducts()[0].GetId() != 7 {
+			t.Errorf("expected products 7 to 5 of 10, got %v", page)
+		}
+	})
+
+	t.Run("should stream every matching product", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		client := newTestGRPCClient(t, app)
+		stream, err := client.StreamProducts(ctx, &productsv1.StreamProductsRequest{Filter: &productsv1.ProductFilter{Limit: 3}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		var ids []int64
+		for {
+			response, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			ids = append(ids, response.GetProduct().GetId())
+		}
+
+		// Assert
+		if !slices.Equal(ids, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+			t.Errorf("expected all 10 products in order, got %v", ids)
+		}
+	})
+	t.Run("should charge calls to the rate limit", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		limits := shared.Config{RequestPerTimeFrame: 1, TimeFrame: time.Minute, Enabled: true}
+		if err := app.rateLimiter.Reload(shared.PolicyConfig{Rules: defaultRateLimitRules}, limits); err != nil {
+			t.Fatal(err)
+		}
+		client := newTestGRPCClient(t, app)
+
+		// Act
+		_, firstErr := client.ListProducts(ctx, &productsv1.ListProductsRequest{})
+		_, secondErr := client.ListProducts(ctx, &productsv1.ListProductsRequest{})
+		stream, _ := client.StreamProducts(ctx, &productsv1.StreamProductsRequest{})
+		_, streamErr := stream.Recv()
+
+		// Assert
+		if firstErr != nil {
+			t.Fatalf("expected the first call to pass, got %v", firstErr)
+		}
+		st := status.Convert(secondErr)
+		if st.Code() != codes.ResourceExhausted {
+			t.Fatalf("expected ResourceExhausted, got %v", st.Code())
+		}
+		if details := st.Details(); len(details) != 1 {
+			t.Errorf("expected a RetryInfo detail, got %v", details)
+		} else if retryInfo, ok := details[0].(*errdetails.RetryInfo); !ok || retryInfo.GetRetryDelay().AsDuration() <= 0 {
+			t.Errorf("expected a positive retry delay, got %v", details[0])
+		}
+		if code := status.Code(streamErr); code != codes.ResourceExhausted {
+			t.Errorf("expected the stream to be rate limited, got %v", code)
+		}
+	})
+
+	t.Run("should map state conflicts to FailedPrecondition", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		err := fmt.Errorf("transition: %w", &store.InvalidTransitionError{ID: 1, From: store.Archived, To: store.Published})
+
+		// Act
+		mapped := app.grpcError("/products.v1.ProductService/TransitionProduct", err)
+
+		// Assert
+		if code := status.Code(mapped); code != codes.FailedPrecondition {
+			t.Errorf("expected FailedPrecondition, got %v", code)
+		}
+	})
+}
+
+func TestGraphQL(t *testing.T) {
+	type graphQLResponse struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []struct {
+			Message    string         `json:"message"`
+			Extensions map[string]any `json:"extensions"`
+		} `json:"errors"`
+	}
+
+	executeGraphQL := func(t *testing.T, mux http.Handler, query string, variables map[string]any) (*httptest.ResponseRecorder, graphQLResponse) {
+		t.Helper()
+
+		body, _ := json.Marshal(GraphQLRequest{Query: query, Variables: variables})
+		rr := executeRequest(httptest.NewRequest(http.MethodPost, "/api/v1/graphql", bytes.NewReader(body)), mux)
+
+		var response graphQLResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return rr, response
+	}
+
+	t.Run("should list the requested fields of a page of products", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		mux := app.mount()
+
+		// Act
+		rr, response := executeGraphQL(t, mux, `query($limit: Int) { products(limit: $limit, order: DESC) { total order items { id name } } }`, map[string]any{"limit": 2})
+
+		// Assert
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		if len(response.Errors) > 0 {
+			t.Fatalf("expected no errors, got %v", response.Errors)
+		}
+		expected := `{"items":[{"id":"10","name":"Product 10"},{"id":"9","name":"Product 9"}],"order":"DESC","total":10}`
+		if page := string(response.Data["products"]); page != expected {
+			t.Errorf("expected %s, got %s", expected, page)
+		}
+	})
+
+	t.Run("should load the products of a query in one batch", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		mux := app.mount()
+
+		// Act
+		_, response := executeGraphQL(t, mux, `{ first: product(id: "1") { name } second: product(id: "2") { name } missing: product(id: "99") { name } }`, nil)
+		metrics := executeRequest(httptest.NewRequest(http.MethodGet, "/metrics", nil), app.mountAdmin(mux)).Body.String()
+
+		// Assert
+		if string(response.Data["first"]) != `{"name":"Product 1"}` || string(response.Data["missing"]) != "null" {
+			t.Errorf("expected product 1 and no missing product, got %v", response.Data)
+		}
+		if !strings.Contains(metrics, `store_operation_duration_seconds_count{method="GetMany"} 1`) || strings.Contains(metrics, `method="Get"}`) {
+			t.Errorf("expected a single GetMany to load the products")
+		}
+	})
+
+	t.Run("should create, update and delete a product", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		mux := app.mount()
+		_, created := executeGraphQL(t, mux, `mutation { createProduct(input: {name: "Lamp", description: "Description", category: "Home"}) { id status } }`, nil)
+		var product struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		}
+		_ = json.Unmarshal(created.Data["createProduct"], &product)
+
+		// Act
+		_, updated := executeGraphQL(t, mux, `mutation($id: ID!) { updateProduct(id: $id, input: {name: "Desk lamp", description: "Description", category: "Home"}) { name version } }`, map[string]any{"id": product.ID})
+		_, deleted := executeGraphQL(t, mux, `mutation($id: ID!) { deleteProduct(id: $id) }`, map[string]any{"id": product.ID})
+
+		// Assert
+		if product.ID != "11" || product.Status != "draft" {
+			t.Errorf("expected a draft product 11, got %+v", product)
+		}
+		if string(updated.Data["updateProduct"]) != `{"name":"Desk lamp","version":2}` {
+			t.Errorf("expected the updated product, got %s", updated.Data["updateProduct"])
+		}
+		if string(deleted.Data["deleteProduct"]) != "true" {
+			t.Errorf("expected the product to be deleted, got %v", deleted)
+		}
+	})
+
+	t.Run("should report invalid fields in the error extensions", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		mux := app.mount()
+
+		// Act
+		_, response := executeGraphQL(t, mux, `mutation { createProduct(input: {name: "", description: "Description", category: "Home"}) { id } }`, nil)
+
+		// Assert
+		if len(response.Errors) != 1 {
+			t.Fatalf("expected one error, got %v", response.Errors)
+		}
+		extensions := response.Errors[0].Extensions
+		if extensions["code"] != "BAD_USER_INPUT" || fmt.Sprint(extensions["fields"]) != "[map[field:name message:is required]]" {
+			t.Errorf("expected the missing name, got %v", extensions)
+		}
+	})
+
+	t.Run("should report invalid fields of an update", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		mux := app.mount()
+
+		// Act
+		_, response := executeGraphQL(t, mux, `mutation { updateProduct(id: 1, input: {name: "Lamp", description: "", category: "Home"}) { id } }`, nil)
+
+		// Assert
+		if len(response.Errors) != 1 {
+			t.Fatalf("expected one error, got %v", response.Errors)
+		}
+		extensions := response.Errors[0].Extensions
+		if extensions["code"] != "BAD_USER_INPUT" || fmt.Sprint(extensions["fields"]) != "[map[field:description message:is required]]" {
+			t.Errorf("expected the missing description, got %v", extensions)
+		}
+	})
+
+	t.Run("should reject queries above the complexity limit", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		app.config.GraphQL.MaxComplexity = 40
+		mux := app.mount()
+
+		// Act
+		_, response := executeGraphQL(t, mux, `{ products(limit: 20) { items { id name } } }`, nil)
+
+		// Assert
+		if len(response.Errors) != 1 || response.Errors[0].Extensions["code"] != "COMPLEXITY_LIMIT_EXCEEDED" {
+			t.Fatalf("expected the complexity limit error, got %v", response.Errors)
+		}
+		if complexity := response.Errors[0].Extensions["complexity"]; complexity != float64(42) {
+			t.Errorf("expected a complexity of 42, got %v", complexity)
+		}
+	})
+
+	t.Run("should count the default of a limit variable", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		app.config.GraphQL.MaxComplexity = 40
+		mux := app.mount()
+
+		// Act
+		_, response := executeGraphQL(t, mux, `query($limit: Int = 50) { products(limit: $limit) { items { id name } } }`, nil)
+
+		// Assert
+		if len(response.Errors) != 1 {
+			t.Fatalf("expected the complexity limit error, got %v", response.Errors)
+		}
+		if complexity := response.Errors[0].Extensions["complexity"]; complexity != float64(102) {
+			t.Errorf("expected a complexity of 102, got %v", complexity)
+		}
+	})
+
+	t.Run("should charge the complexity to the rate limit", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		limits := shared.Config{RequestPerTimeFrame: 20, TimeFrame: time.Minute, Enabled: true}
+		if err := app.rateLimiter.Reload(shared.PolicyConfig{Rules: defaultRateLimitRules}, limits); err != nil {
+			t.Fatal(err)
+		}
+		mux := app.mount()
+
+		// Act
+		first, _ := executeGraphQL(t, mux, `{ products(limit: 5) { items { id name } } }`, nil)
+		body, _ := json.Marshal(GraphQLRequest{Query: `{ products(limit: 5) { items { id name } } }`})
+		second := executeRequest(httptest.NewRequest(http.MethodPost, "/api/v1/graphql", bytes.NewReader(body)), mux)
+
+		// Assert
+		assertResponseCode(t, http.StatusTooManyRequests, second.Code)
+		if remaining := first.Header().Get("RateLimit-Remaining"); remaining != "7" {
+			t.Errorf("expected the request and its complexity of 12 to be charged, got %q remaining", remaining)
+		}
+	})
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should create, publish and delete a product", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		c := newTestClient(t, app, client.WithAdminToken(testAdminToken))
+
+		// Act
+		created, createErr := c.CreateProduct(ctx, client.CreateProductRequest{Name: "Lamp", Description: "Description", Category: "Home"})
+		updated, updateErr := c.UpdateProduct(ctx, created.ID, client.UpdateProductRequest{Name: "Desk lamp", Description: "Description", Category: "Home"})
+		_, reviewErr := c.TransitionProduct(ctx, created.ID, client.Review)
+		published, publishErr := c.TransitionProduct(ctx, created.ID, client.Published)
+		bySlug, slugErr := c.GetProductBySlug(ctx, "lamp")
+		deleteErr := c.DeleteProduct(ctx, created.ID)
+		_, getErr := c.GetProduct(ctx, created.ID)
+
+		// Assert
+		if err := errors.Join(createErr, updateErr, reviewErr, publishErr, slugErr, deleteErr); err != nil {
+			t.Fatalf("expected no errors, got %v", err)
+		}
+		if created.Status != client.Draft || created.CreatedAt.IsZero() {
+			t.Errorf("expected a draft product, got %+v", created)
+		}
+		if updated.Name != "Desk lamp" || updated.Version != 2 {
+			t.Errorf("expected the updated product, got %+v", updated)
+		}
+		if published.Status != client.Published || bySlug.ID != created.ID || bySlug.Slug != "desk-lamp" {
+			t.Errorf("expected the published product under its new slug, got %+v and %+v", published, bySlug)
+		}
+		if !errors.Is(getErr, client.ErrNotFound) {
+			t.Errorf("expected ErrNotFound after the delete, got %v", getErr)
+		}
+	})
+
+	t.Run("should iterate over all pages", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		c := newTestClient(t, app)
+
+		// Act
+		var ids []int64
+		for product, err := range c.Products(ctx, client.ListProductsOptions{Limit: 3}) {
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			ids = append(ids, product.ID)
+		}
+		page, err := c.ListProducts(ctx, client.ListProductsOptions{Limit: 3, Page: 4})
+
+		// Assert
+		if !slices.Equal(ids, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+			t.Errorf("expected products 1 to 10, got %v", ids)
+		}
+		if err != nil || page.Total != 10 || len(page.Data) != 1 {
+			t.Errorf("expected the last page to hold 1 of 10 products, got %+v, %v", page, err)
+		}
+	})
+
+	t.Run("should return the field errors of a rejected product", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		c := newTestClient(t, app)
+
+		// Act
+		_, err := c.CreateProduct(ctx, client.CreateProductRequest{Description: "Description", Category: "Home"})
+
+		// Assert
+		var apiErr *client.APIError
+		if !errors.Is(err, client.ErrBadRequest) || !errors.As(err, &apiErr) {
+			t.Fatalf("expected ErrBadRequest, got %v", err)
+		}
+		if !slices.Equal(apiErr.Errors, []client.FieldError{{Field: "name", Message: "is required"}}) {
+			t.Errorf("expected the name to be required, got %v", apiErr.Errors)
+		}
+		if apiErr.Instance == "" {
+			t.Error("expected the request ID as the instance")
+		}
+	})
+
+	t.Run("should require the admin token for transitions", func(t *testing.T) {
+		// Arrange
+		app := newTestApplication(t)
+		c := newTestClient(t, app)
+
+		// Act
+		_, err := c.TransitionProduct(ctx, 1, client.Archived)
+
+		// Assert
+		if !errors.Is(err, client.ErrUnauthorized) {
+			t.Errorf("expected ErrUnauthorized, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"github.com/dawidpereira/online-store-go/products/internal/store"
+	"net/http"
+	"time"
+)
+
+type TransitionProductRequest struct {
+	Status store.Status `json:"status" validate:"required,oneof=draft review published archived"`
+}
+
+// Transition product godoc
+//
+//	@Summary		Change the status of a product
+//	@Description	Move a product through the draft, review, published and archived statuses
+//	@Tags			publication
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param			id		path		int							true	"Product ID"
+//	@Param			request	body		TransitionProductRequest	true	"Target status"
+//	@Success		200		{object}	store.Product
+//	@Failure		400		{object}	shared.Problem
+//	@Failure		401		{object}	shared.Problem
+//	@Failure		404		{object}	shared.Problem
+//	@Failure		409		{object}	shared.Problem
+//	@Failure		500		{object}	shared.Problem
+//	@Router			/products/{id}/status [post]
+func (app *application) transitionProductHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "transitionProductHandler")
+	defer span.End()
+
+	id, err := readIDParam(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var transitionRequest TransitionProductRequest
+	if err := readJSON(w, r, &transitionRequest, app.logger); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(transitionRequest); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	product, err := app.store.Products.Transition(r.Context(), id, transitionRequest.Status)
+	if err != nil {
+		var notFoundErr *store.ProductNotFoundError
+		var invalidTransitionErr *store.InvalidTransitionError
+		switch {
+		case errors.As(err, &notFoundErr):
+			app.notFoundError(w, r)
+		case errors.As(err, &invalidTransitionErr):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, product); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ScheduleProductRequest struct {
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// Schedule product godoc
+//
+//	@Summary		Schedule the publication of a product
+//	@Description	Set when a product in review is published and when a published product is archived. Omitted times clear the schedule. A publish_at needs a product in review, and an unpublish_at a published product or a publish_at.
+//	@Tags			publication
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param			id		path		int						true	"Product ID"
+//	@Param			request	body		ScheduleProductRequest	true	"Publication schedule"
+//	@Success		200		{object}	store.Product
+//	@Failure		400		{object}	shared.Problem
+//	@Failure		401		{object}	shared.Problem
+//	@Failure		404		{object}	shared.Problem
+//	@Failure		409		{object}	shared.Problem
+//	@Failure		500		{object}	shared.Problem
+//	@Router			/products/{id}/schedule [put]
+func (app *application) scheduleProductHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "scheduleProductHandler")
+	defer span.End()
+
+	id, err := readIDParam(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var scheduleRequest ScheduleProductRequest
+	if err := readJSON(w, r, &scheduleRequest, app.logger); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if scheduleRequest.PublishAt != nil && scheduleRequest.UnpublishAt != nil &&
+		!scheduleRequest.UnpublishAt.After(*scheduleRequest.PublishAt) {
+		app.badRequestError(w, r, errors.New("unpublish_at must be after publish_at"))
+		return
+	}
+
+	product, err := app.store.Products.Schedule(r.Context(), id, scheduleRequest.PublishAt, scheduleRequest.UnpublishAt)
+	if err != nil {
+		var notFoundErr *store.ProductNotFoundError
+		var invalidScheduleErr *store.InvalidScheduleError
+		switch {
+		case errors.As(err, &notFoundErr):
+			app.notFoundError(w, r)
+		case errors.As(err, &invalidScheduleErr):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, product); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
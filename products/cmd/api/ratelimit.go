@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/dawidpereira/online-store-go/shared"
+	"net/http"
+)
+
+// defaultRateLimitRules keep operational endpoints out of the default rate limit policy.
+var defaultRateLimitRules = []shared.Rule{
+	{Route: "/api/v1/healthcheck", Exempt: true},
+	{Route: "/api/v1/swagger/*", Exempt: true},
+	{Route: "/livez", Exempt: true},
+	{Route: "/readyz", Exempt: true},
+	{Route: "/*", Policy: shared.DefaultPolicyName},
+}
+
+// loadRateLimitPolicies reads the policies from path, or returns the default rules when path is empty.
+func loadRateLimitPolicies(path string) (shared.PolicyConfig, error) {
+	if path == "" {
+		return shared.PolicyConfig{Rules: defaultRateLimitRules}, nil
+	}
+
+	return shared.LoadPolicyConfig(path)
+}
+
+// authenticate identifies the admin for user keyed rate limit policies, and partners
+// sending a configured API key for the api_key and tenant policies. Unknown API keys
+// are ignored, so their requests count as anonymous.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.isAdmin(r) {
+			r = r.WithContext(shared.WithUser(r.Context(), "admin"))
+		}
+		if key, tenant, ok := app.partner(r.Header.Get(shared.APIKeyHeader)); ok {
+			r = r.WithContext(shared.WithTenant(shared.WithAPIKey(r.Context(), key), tenant))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"github.com/dawidpereira/online-store-go/shared"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// settings are the config values that change without a restart. They are replaced as
+// a whole, so a request never sees half of a reload.
+type settings struct {
+	features    map[string]bool
+	corsOrigins []string
+	apiKeys     []apiKey
+}
+
+// newSettings builds the settings of a validated config.
+func newSettings(cfg config) *settings {
+	keys, _ := parseAPIKeys(cfg.APIKeys)
+	features := make(map[string]bool, len(cfg.Features))
+	for _, feature := range cfg.Features {
+		features[feature] = true
+	}
+
+	return &settings{
+		features:    features,
+		corsOrigins: cfg.CORSAllowedOrigins,
+		apiKeys:     keys,
+	}
+}
+
+func (app *application) featureEnabled(name string) bool {
+	return app.settings.Load().features[name]
+}
+
+// reloadable reports whether the setting, named by its env variable, is applied on SIGHUP.
+func reloadable(key string) bool {
+	return strings.HasPrefix(key, "RATE_LIMIT_") || slices.Contains([]string{"LOG_LEVEL", "LOG_COMPONENT_LEVELS", "FEATURES", "CORS_ALLOWED_ORIGINS", "API_KEYS"}, key)
+}
+
+// reload re-reads the config and applies the reloadable settings. Changes to other
+// settings are logged and take effect after a restart. It returns the config in effect,
+// which the next reload compares against. Nothing is applied when the config is invalid:
+// it is validated as a whole first, and the rate limiter swaps its policies only once
+// they are all built.
+func (app *application) reload(current config) (config, error) {
+	if app.loadConfig == nil {
+		return current, errors.New("config reload is not configured")
+	}
+
+	cfg, err := app.loadConfig()
+	if err != nil {
+		return current, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return current, err
+	}
+
+	policies, err := loadRateLimitPolicies(cfg.RateLimitPolicies)
+	if err != nil {
+		return current, err
+	}
+
+	if err := app.rateLimiter.Reload(policies, cfg.RateLimiter); err != nil {
+		return current, err
+	}
+	if err := app.loggers.Apply(cfg.Log); err != nil {
+		return current, err
+	}
+	app.settings.Store(newSettings(cfg))
+
+	applied := current
+	applied.Log.Level = cfg.Log.Level
+	applied.Log.ComponentLevels = cfg.Log.ComponentLevels
+	applied.Features = cfg.Features
+	applied.CORSAllowedOrigins = cfg.CORSAllowedOrigins
+	applied.APIKeys = cfg.APIKeys
+	applied.RateLimiter = cfg.RateLimiter
+	applied.RateLimitPolicies = cfg.RateLimitPolicies
+
+	previous, next := shared.RedactConfig(&current), shared.RedactConfig(&cfg)
+	for _, key := range slices.Sorted(maps.Keys(next)) {
+		if previous[key] == next[key] {
+			continue
+		}
+
+		if reloadable(key) {
+			app.logger.Infow("setting reloaded", "setting", key, "from", previous[key], "to", next[key])
+		} else {
+			app.logger.Warnw("setting changed, restart to apply it", "setting", key)
+		}
+	}
+
+	return applied, nil
+}
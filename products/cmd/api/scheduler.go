@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"github.com/dawidpereira/online-store-go/shared"
+	"time"
+)
+
+// runScheduler applies scheduled publications until the context is cancelled.
+func (app *application) runScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			app.applySchedule(ctx, now)
+		}
+	}
+}
+
+func (app *application) applySchedule(ctx context.Context, now time.Time) {
+	ctx, span := app.tracer().Start(ctx, "applySchedule")
+	defer span.End()
+
+	products, err := app.store.Products.ApplySchedule(ctx, now)
+	if err != nil {
+		span.RecordError(err)
+		shared.TraceLogger(ctx, app.logger).Errorw("scheduled publication failed", "error", err.Error())
+		return
+	}
+
+	for _, product := range products {
+		app.logger.Infow("scheduled publication applied", "id", product.ID, "status", product.Status)
+	}
+}
@@ -1,32 +1,86 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"github.com/dawidpereira/online-store-go/products/client"
 	"github.com/dawidpereira/online-store-go/products/internal/store"
+	productsv1 "github.com/dawidpereira/online-store-go/products/proto/products/v1"
 	"github.com/dawidpereira/online-store-go/shared"
-	"go.uber.org/zap"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func newTestApplication(t *testing.T) *application {
 	t.Helper()
 
-	logger := zap.NewNop().Sugar()
-	storage := store.NewMockStorage()
-
-	return &application{
-		logger: logger,
-		store:  storage,
-		rateLimiter: shared.NewFixedWindowRateLimiter(shared.Config{
+	loggers, err := shared.NewLoggersWithCore(zapcore.NewNopCore(), shared.LogConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := loggers.Logger("")
+	metrics := shared.NewMetrics()
+	storage, err := store.NewInstrumentedStorage(store.NewMockStorage(), metrics.Registerer(), loggers.Logger(storeComponent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config{
+		Env:               shared.ProfileTest,
+		AdminToken:        testAdminToken,
+		SchedulerInterval: time.Minute,
+		Cache:             store.CacheConfig{Backend: store.NoCacheBackend},
+		RateLimiter: shared.Config{
 			RequestPerTimeFrame: 100,
 			TimeFrame:           1,
 			Enabled:             true,
-		}, logger),
+		},
+	}
+	rateLimiter, err := shared.NewPolicyEngine(shared.PolicyConfig{Rules: defaultRateLimitRules}, cfg.RateLimiter, loggers.Logger(rateLimitComponent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rateLimiter.SetMetrics(metrics)
+	tracerProvider := noop.NewTracerProvider()
+	storage = store.NewTracedStorage(storage, tracerProvider)
+	t.Cleanup(func() {
+		_ = rateLimiter.Close()
+	})
+	clientIP, err := shared.NewClientIPResolver(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	health := shared.NewHealth(time.Second)
+	health.Register("storage", storage)
+
+	app := &application{
+		config:         cfg,
+		logger:         logger,
+		loggers:        loggers,
+		store:          storage,
+		rateLimiter:    rateLimiter,
+		metrics:        metrics,
+		tracerProvider: tracerProvider,
+		clientIP:       clientIP,
+		health:         health,
+		idempotency:    shared.NewIdempotency(shared.IdempotencyConfig{TTL: time.Hour, LockTimeout: time.Minute}, shared.NewMemoryIdempotencyStore(), logger),
 	}
+	app.settings.Store(newSettings(app.config))
+
+	return app
 }
 
+const testAdminToken = "test-admin-token"
+
 func executeRequest(req *http.Request, mux http.Handler) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
@@ -47,3 +101,53 @@ func assertResponseCode(t *testing.T, expected, actual int) {
 		t.Errorf("expected status %d, got %d", expected, actual)
 	}
 }
+
+func authorizeAdmin(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+}
+
+// newTestGRPCClient serves the gRPC API of app on an in-memory connection.
+func newTestGRPCClient(t *testing.T, app *application) productsv1.ProductServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := app.newGRPCServer()
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		server.Stop()
+	})
+
+	return productsv1.NewProductServiceClient(conn)
+}
+
+func authorizeGRPCAdmin(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+testAdminToken)
+}
+
+// newTestClient returns a client of the HTTP API of app, served by an httptest server.
+func newTestClient(t *testing.T, app *application, options ...client.Option) *client.Client {
+	t.Helper()
+
+	server := httptest.NewServer(app.mount())
+	t.Cleanup(server.Close)
+
+	c, err := client.New(server.URL, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
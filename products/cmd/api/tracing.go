@@ -0,0 +1,19 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const tracerName = "github.com/dawidpereira/online-store-go/products/cmd/api"
+
+func (app *application) tracer() trace.Tracer {
+	return app.tracerProvider.Tracer(tracerName)
+}
+
+// startSpan starts a span for a handler, returning the request carrying it so the store
+// spans become its children.
+func (app *application) startSpan(r *http.Request, name string) (*http.Request, trace.Span) {
+	ctx, span := app.tracer().Start(r.Context(), name)
+	return r.WithContext(ctx), span
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"github.com/dawidpereira/online-store-go/products/internal/store"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+type TranslationRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"required,max=100"`
+	Category    string `json:"category" validate:"required,max=50"`
+}
+
+// List translations godoc
+//
+//	@Summary		List product translations
+//	@Description	List all translations of a product keyed by locale
+//	@Tags			translations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Product ID"
+//	@Success		200	{object}	map[string]store.ProductTranslation
+//	@Failure		400	{object}	shared.Problem
+//	@Failure		404	{object}	shared.Problem
+//	@Failure		500	{object}	shared.Problem
+//	@Router			/products/{id}/translations [get]
+func (app *application) listTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "listTranslationsHandler")
+	defer span.End()
+
+	id, err := readIDParam(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	product, err := app.store.Products.Get(r.Context(), id)
+	if err != nil {
+		var notFoundErr *store.ProductNotFoundError
+		if errors.As(err, &notFoundErr) {
+			app.notFoundError(w, r)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if product.Status != store.Published && !app.isAdmin(r) {
+		app.notFoundError(w, r)
+		return
+	}
+
+	translations := product.Translations
+	if translations == nil {
+		translations = map[string]store.ProductTranslation{}
+	}
+
+	if err := writeJSON(w, http.StatusOK, translations); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Put translation godoc
+//
+//	@Summary		Create or replace a product translation
+//	@Description	Create or replace the translation of a product for a single locale
+//	@Tags			translations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Product ID"
+//	@Param			locale	path		string				true	"Locale, e.g. de-AT"
+//	@Param			request	body		TranslationRequest	true	"Translated product details"
+//	@Success		200		{object}	store.Product
+//	@Failure		400		{object}	shared.Problem
+//	@Failure		404		{object}	shared.Problem
+//	@Failure		500		{object}	shared.Problem
+//	@Router			/products/{id}/translations/{locale} [put]
+func (app *application) putTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "putTranslationHandler")
+	defer span.End()
+
+	id, err := readIDParam(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	locale, err := readLocaleParam(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var translationRequest TranslationRequest
+	if err := readJSON(w, r, &translationRequest, app.logger); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(translationRequest); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	translation := store.ProductTranslation{
+		Name:        translationRequest.Name,
+		Description: translationRequest.Description,
+		Category:    translationRequest.Category,
+	}
+
+	product, err := app.store.Products.SetTranslation(r.Context(), id, locale, translation)
+	if err != nil {
+		var notFoundErr *store.ProductNotFoundError
+		if errors.As(err, &notFoundErr) {
+			app.notFoundError(w, r)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, product); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Delete translation godoc
+//
+//	@Summary		Delete a product translation
+//	@Description	Delete the translation of a product for a single locale
+//	@Tags			translations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int		true	"Product ID"
+//	@Param			locale	path	string	true	"Locale, e.g. de-AT"
+//	@Success		204
+//	@Failure		400	{object}	shared.Problem
+//	@Failure		404	{object}	shared.Problem
+//	@Failure		500	{object}	shared.Problem
+//	@Router			/products/{id}/translations/{locale} [delete]
+func (app *application) deleteTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := app.startSpan(r, "deleteTranslationHandler")
+	defer span.End()
+
+	id, err := readIDParam(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	locale, err := readLocaleParam(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.store.Products.DeleteTranslation(r.Context(), id, locale); err != nil {
+		var notFoundErr *store.ProductNotFoundError
+		var translationNotFoundErr *store.TranslationNotFoundError
+		if errors.As(err, &notFoundErr) || errors.As(err, &translationNotFoundErr) {
+			app.notFoundError(w, r)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func readLocaleParam(r *http.Request) (string, error) {
+	locale := store.NormalizeLocale(chi.URLParam(r, "locale"))
+	if err := Validate.Var(locale, "bcp47_language_tag"); err != nil {
+		return "", err
+	}
+
+	return locale, nil
+}
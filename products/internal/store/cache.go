@@ -0,0 +1,185 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+type CacheBackend string
+
+const (
+	NoCacheBackend     CacheBackend = "none"
+	MemoryCacheBackend CacheBackend = "memory"
+	RedisCacheBackend  CacheBackend = "redis"
+)
+
+// cacheTimeout bounds Redis cache operations, so a slow cache doesn't slow reads down.
+const cacheTimeout = 50 * time.Millisecond
+
+type CacheConfig struct {
+	Backend CacheBackend  `env:"BACKEND" default:"memory"`
+	TTL     time.Duration `env:"TTL" default:"30s"`
+	// Size is the number of entries kept by the memory cache.
+	Size     int    `env:"SIZE" default:"10000"`
+	RedisURL string `env:"REDIS_URL" secret:"true"`
+}
+
+// Cache keeps encoded values for a TTL. List keys include the generation, so bumping it
+// invalidates every cached list at once.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, keys ...string) error
+	Generation(ctx context.Context) (int64, error)
+	NextGeneration(ctx context.Context) error
+	Close() error
+}
+
+// Validate rejects a TTL or, for the memory cache, a size that would keep no entries.
+func (config CacheConfig) Validate() error {
+	if config.Backend == NoCacheBackend {
+		return nil
+	}
+
+	var errs []error
+	if config.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("STORE_CACHE_TTL must be positive, got %v", config.TTL))
+	}
+	if (config.Backend == MemoryCacheBackend || config.Backend == "") && config.Size <= 0 {
+		errs = append(errs, fmt.Errorf("STORE_CACHE_SIZE must be positive, got %d", config.Size))
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewCache returns the cache of the configured backend, or nil for NoCacheBackend.
+func NewCache(config CacheConfig) (Cache, error) {
+	switch config.Backend {
+	case NoCacheBackend:
+		return nil, nil
+	case MemoryCacheBackend, "":
+		return NewMemoryCache(config.Size, config.TTL), nil
+	case RedisCacheBackend:
+		options, err := redis.ParseURL(config.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache redis url: %w", err)
+		}
+
+		cache := NewRedisCache(redis.NewClient(options), config.TTL)
+		cache.ownsClient = true
+
+		return cache, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q, expected none, memory or redis", config.Backend)
+	}
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// MemoryCache keeps the most recently used entries in process memory.
+type MemoryCache struct {
+	mu         sync.Mutex
+	size       int
+	ttl        time.Duration
+	entries    map[string]*list.Element
+	recent     *list.List
+	generation int64
+	now        func() time.Time
+}
+
+func NewMemoryCache(size int, ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		recent:  list.New(),
+		now:     time.Now,
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		return nil, false, nil
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(element)
+		return nil, false, nil
+	}
+
+	c.recent.MoveToFront(element)
+
+	return entry.value, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if element, exists := c.entries[key]; exists {
+		entry := element.Value.(*memoryEntry)
+		entry.value, entry.expires = value, expires
+		c.recent.MoveToFront(element)
+		return nil
+	}
+
+	c.entries[key] = c.recent.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for c.recent.Len() > c.size {
+		c.remove(c.recent.Back())
+	}
+
+	return nil
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if element, exists := c.entries[key]; exists {
+			c.remove(element)
+		}
+	}
+
+	return nil
+}
+
+func (c *MemoryCache) remove(element *list.Element) {
+	c.recent.Remove(element)
+	delete(c.entries, element.Value.(*memoryEntry).key)
+}
+
+func (c *MemoryCache) Generation(ctx context.Context) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation, nil
+}
+
+func (c *MemoryCache) NextGeneration(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	return nil
+}
+
+func (c *MemoryCache) Close() error {
+	return nil
+}
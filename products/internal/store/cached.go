@@ -0,0 +1,293 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"time"
+)
+
+// CachedProductStorage serves Get and List from a cache, loading misses from the wrapped
+// storage. Concurrent misses of a key share one load. Writes invalidate the product and
+// every cached list, but a load racing with a write may cache the old value until the
+// TTL expires. Cache failures are logged and the wrapped storage serves the request.
+type CachedProductStorage struct {
+	next     ProductStorage
+	cache    Cache
+	group    singleflight.Group
+	requests *prometheus.CounterVec
+	logger   *zap.SugaredLogger
+}
+
+// NewCachedStorage wraps the products of storage with cache, registering the hit and
+// miss counter with registerer.
+func NewCachedStorage(storage Storage, cache Cache, registerer prometheus.Registerer, logger *zap.SugaredLogger) (Storage, error) {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "store_cache_requests_total",
+		Help: "Store cache lookups by method and result, one of hit, miss and error.",
+	}, []string{"method", "result"})
+
+	if err := registerer.Register(requests); err != nil {
+		return storage, err
+	}
+
+	storage.Products = &CachedProductStorage{next: storage.Products, cache: cache, requests: requests, logger: logger}
+
+	return storage, nil
+}
+
+func productKey(id int64) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+func listKey(generation int64, query ListProductsQuery) (string, error) {
+	encoded, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+
+	return fmt.Sprintf("products:%d:%s", generation, hex.EncodeToString(sum[:16])), nil
+}
+
+// lookup decodes the cached value of key into value, reporting whether it was found.
+func (s *CachedProductStorage) lookup(ctx context.Context, method, key string, value any) bool {
+	cached, found, err := s.cache.Get(ctx, key)
+	if err != nil {
+		s.requests.WithLabelValues(method, "error").Inc()
+		s.logger.Warnw("store cache lookup failed", "method", method, "error", err.Error())
+		return false
+	}
+
+	if !found || json.Unmarshal(cached, value) != nil {
+		s.requests.WithLabelValues(method, "miss").Inc()
+		return false
+	}
+
+	s.requests.WithLabelValues(method, "hit").Inc()
+
+	return true
+}
+
+// load calls the wrapped storage once for all concurrent misses of key and caches the result.
+// The load outlives a cancelled caller, as other callers may wait for it.
+func (s *CachedProductStorage) load(ctx context.Context, key string, load func(ctx context.Context) (any, error)) (any, error) {
+	result, err, _ := s.group.Do(key, func() (any, error) {
+		ctx := context.WithoutCancel(ctx)
+
+		result, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded, err := json.Marshal(result)
+		if err == nil {
+			err = s.cache.Set(ctx, key, encoded)
+		}
+		if err != nil {
+			s.logger.Warnw("store cache update failed", "key", key, "error", err.Error())
+		}
+
+		return result, nil
+	})
+
+	return result, err
+}
+
+// invalidate removes the products from the cache and starts a new generation of lists.
+func (s *CachedProductStorage) invalidate(ctx context.Context, ids ...int64) {
+	ctx = context.WithoutCancel(ctx)
+
+	if len(ids) > 0 {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = productKey(id)
+		}
+		if err := s.cache.Delete(ctx, keys...); err != nil {
+			s.logger.Errorw("store cache invalidation failed", "ids", ids, "error", err.Error())
+		}
+	}
+
+	if err := s.cache.NextGeneration(ctx); err != nil {
+		s.logger.Errorw("store cache invalidation failed", "error", err.Error())
+	}
+}
+
+func (s *CachedProductStorage) Create(ctx context.Context, product *Product) error {
+	err := s.next.Create(ctx, product)
+	if err == nil {
+		s.invalidate(ctx)
+	}
+
+	return err
+}
+
+func (s *CachedProductStorage) List(ctx context.Context, query ListProductsQuery) (PaginatedResponse, error) {
+	generation, err := s.cache.Generation(ctx)
+	if err != nil {
+		s.requests.WithLabelValues("List", "error").Inc()
+		s.logger.Warnw("store cache lookup failed", "method", "List", "error", err.Error())
+		return s.next.List(ctx, query)
+	}
+
+	key, err := listKey(generation, query)
+	if err != nil {
+		return s.next.List(ctx, query)
+	}
+
+	var products []*Product
+	page := PaginatedResponse{Data: &products}
+	if s.lookup(ctx, "List", key, &page) {
+		page.Data = products
+		return page, nil
+	}
+
+	result, err := s.load(ctx, key, func(ctx context.Context) (any, error) {
+		return s.next.List(ctx, query)
+	})
+	if err != nil {
+		return PaginatedResponse{}, err
+	}
+
+	return result.(PaginatedResponse), nil
+}
+
+func (s *CachedProductStorage) Get(ctx context.Context, id int64) (*Product, error) {
+	key := productKey(id)
+
+	var product Product
+	if s.lookup(ctx, "Get", key, &product) {
+		return &product, nil
+	}
+
+	result, err := s.load(ctx, key, func(ctx context.Context) (any, error) {
+		return s.next.Get(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*Product), nil
+}
+
+// GetMany serves the cached products and loads the others with one call to the wrapped storage.
+func (s *CachedProductStorage) GetMany(ctx context.Context, ids []int64) ([]*Product, error) {
+	cached := make(map[int64]*Product, len(ids))
+	var missing []int64
+	for _, id := range ids {
+		var product Product
+		if s.lookup(ctx, "GetMany", productKey(id), &product) {
+			cached[id] = &product
+			continue
+		}
+		missing = append(missing, id)
+	}
+
+	if len(missing) > 0 {
+		loaded, err := s.next.GetMany(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, product := range loaded {
+			cached[product.ID] = product
+			encoded, err := json.Marshal(product)
+			if err == nil {
+				err = s.cache.Set(context.WithoutCancel(ctx), productKey(product.ID), encoded)
+			}
+			if err != nil {
+				s.logger.Warnw("store cache update failed", "key", productKey(product.ID), "error", err.Error())
+			}
+		}
+	}
+
+	products := make([]*Product, 0, len(ids))
+	for _, id := range ids {
+		if product, ok := cached[id]; ok {
+			products = append(products, product)
+		}
+	}
+
+	return products, nil
+}
+
+// GetBySlug isn't cached, as renaming a product changes which product a slug resolves to.
+func (s *CachedProductStorage) GetBySlug(ctx context.Context, slug string) (*Product, error) {
+	return s.next.GetBySlug(ctx, slug)
+}
+
+func (s *CachedProductStorage) Update(ctx context.Context, id int64, updatedProduct *Product) (*Product, error) {
+	product, err := s.next.Update(ctx, id, updatedProduct)
+	if err == nil {
+		s.invalidate(ctx, id)
+	}
+
+	return product, err
+}
+
+func (s *CachedProductStorage) Delete(ctx context.Context, id int64) error {
+	err := s.next.Delete(ctx, id)
+	if err == nil {
+		s.invalidate(ctx, id)
+	}
+
+	return err
+}
+
+func (s *CachedProductStorage) SetTranslation(ctx context.Context, id int64, locale string, translation ProductTranslation) (*Product, error) {
+	product, err := s.next.SetTranslation(ctx, id, locale, translation)
+	if err == nil {
+		s.invalidate(ctx, id)
+	}
+
+	return product, err
+}
+
+func (s *CachedProductStorage) DeleteTranslation(ctx context.Context, id int64, locale string) error {
+	err := s.next.DeleteTranslation(ctx, id, locale)
+	if err == nil {
+		s.invalidate(ctx, id)
+	}
+
+	return err
+}
+
+func (s *CachedProductStorage) Transition(ctx context.Context, id int64, status Status) (*Product, error) {
+	product, err := s.next.Transition(ctx, id, status)
+	if err == nil {
+		s.invalidate(ctx, id)
+	}
+
+	return product, err
+}
+
+func (s *CachedProductStorage) Schedule(ctx context.Context, id int64, publishAt, unpublishAt *time.Time) (*Product, error) {
+	product, err := s.next.Schedule(ctx, id, publishAt, unpublishAt)
+	if err == nil {
+		s.invalidate(ctx, id)
+	}
+
+	return product, err
+}
+
+func (s *CachedProductStorage) ApplySchedule(ctx context.Context, now time.Time) ([]*Product, error) {
+	changed, err := s.next.ApplySchedule(ctx, now)
+	if err == nil && len(changed) > 0 {
+		ids := make([]int64, len(changed))
+		for i, product := range changed {
+			ids[i] = product.ID
+		}
+		s.invalidate(ctx, ids...)
+	}
+
+	return changed, err
+}
+
+func (s *CachedProductStorage) Ping(ctx context.Context) error {
+	return s.next.Ping(ctx)
+}
@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProductStorage counts the reads reaching the wrapped storage.
+type countingProductStorage struct {
+	ProductStorage
+	gets    atomic.Int64
+	lists   atomic.Int64
+	batched atomic.Int64
+	release chan struct{}
+}
+
+func (s *countingProductStorage) Get(ctx context.Context, id int64) (*Product, error) {
+	s.gets.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.ProductStorage.Get(ctx, id)
+}
+
+func (s *countingProductStorage) List(ctx context.Context, query ListProductsQuery) (PaginatedResponse, error) {
+	s.lists.Add(1)
+	return s.ProductStorage.List(ctx, query)
+}
+
+func (s *countingProductStorage) GetMany(ctx context.Context, ids []int64) ([]*Product, error) {
+	s.batched.Add(int64(len(ids)))
+	return s.ProductStorage.GetMany(ctx, ids)
+}
+
+func newTestCachedStorage(t *testing.T, cache Cache) (*CachedProductStorage, *countingProductStorage) {
+	t.Helper()
+
+	counting := &countingProductStorage{ProductStorage: NewMockProductStorage()}
+	storage, err := NewCachedStorage(Storage{Products: counting}, cache, prometheus.NewRegistry(), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return storage.Products.(*CachedProductStorage), counting
+}
+
+var testListQuery = ListProductsQuery{PaginatedQuery: PaginatedQuery{Limit: 5, Page: 1, Order: ASC}}
+
+func TestCachedProductStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should serve repeated reads from the cache", func(t *testing.T) {
+		// Arrange
+		cached, counting := newTestCachedStorage(t, NewMemoryCache(100, time.Minute))
+
+		// Act
+		_, _ = cached.Get(ctx, 1)
+		product, err := cached.Get(ctx, 1)
+		_, _ = cached.List(ctx, testListQuery)
+		page, _ := cached.List(ctx, testListQuery)
+
+		// Assert
+		if err != nil || product.ID != 1 {
+			t.Fatalf("expected product 1, got %v, %v", product, err)
+		}
+		if gets, lists := counting.gets.Load(), counting.lists.Load(); gets != 1 || lists != 1 {
+			t.Errorf("expected one get and one list to reach the storage, got %d and %d", gets, lists)
+		}
+		if products, ok := page.Data.([]*Product); !ok || len(products) != 5 {
+			t.Errorf("expected 5 cached products, got %v", page.Data)
+		}
+		if hits := testutil.ToFloat64(cached.requests.WithLabelValues("Get", "hit")); hits != 1 {
+			t.Errorf("expected 1 hit, got %v", hits)
+		}
+	})
+
+	t.Run("should invalidate the product and the lists on writes", func(t *testing.T) {
+		// Arrange
+		cached, _ := newTestCachedStorage(t, NewMemoryCache(100, time.Minute))
+		_, _ = cached.Get(ctx, 1)
+		_, _ = cached.List(ctx, testListQuery)
+
+		// Act
+		_, err := cached.Update(ctx, 1, &Product{Name: "Renamed", Description: "Description", Category: "Category"})
+		product, _ := cached.Get(ctx, 1)
+		page, _ := cached.List(ctx, testListQuery)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if product.Name != "Renamed" {
+			t.Errorf("expected the updated product, got %q", product.Name)
+		}
+		if first := page.Data.([]*Product)[0]; first.Name != "Renamed" {
+			t.Errorf("expected the updated product in the list, got %q", first.Name)
+		}
+	})
+
+	t.Run("should load only the uncached products of a batch", func(t *testing.T) {
+		// Arrange
+		cached, counting := newTestCachedStorage(t, NewMemoryCache(100, time.Minute))
+		_, _ = cached.Get(ctx, 2)
+
+		// Act
+		products, err := cached.GetMany(ctx, []int64{3, 2, 99})
+		again, _ := cached.GetMany(ctx, []int64{3, 2})
+
+		// Assert
+		if err != nil || len(products) != 2 || products[0].ID != 3 || products[1].ID != 2 {
+			t.Fatalf("expected products 3 and 2, got %v, %v", products, err)
+		}
+		if len(again) != 2 {
+			t.Errorf("expected the cached products, got %v", again)
+		}
+		if batched := counting.batched.Load(); batched != 2 {
+			t.Errorf("expected products 3 and 99 to reach the storage, got %d ids", batched)
+		}
+	})
+
+	t.Run("should load concurrent misses once", func(t *testing.T) {
+		// Arrange
+		cached, counting := newTestCachedStorage(t, NewMemoryCache(100, time.Minute))
+		counting.release = make(chan struct{})
+		var wg sync.WaitGroup
+
+		// Act
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = cached.Get(ctx, 1)
+			}()
+		}
+		for counting.gets.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(counting.release)
+		wg.Wait()
+
+		// Assert
+		if gets := counting.gets.Load(); gets != 1 {
+			t.Errorf("expected one get to reach the storage, got %d", gets)
+		}
+	})
+
+	t.Run("should share invalidations through redis", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		first, _ := newTestCachedStorage(t, NewRedisCache(client, time.Minute))
+		second, counting := newTestCachedStorage(t, NewRedisCache(client, time.Minute))
+		_, _ = second.List(ctx, testListQuery)
+
+		// Act
+		_ = first.Create(ctx, &Product{Name: "New", Description: "Description", Category: "Category"})
+		_, _ = second.List(ctx, testListQuery)
+
+		// Assert
+		if lists := counting.lists.Load(); lists != 2 {
+			t.Errorf("expected the list to be loaded again after the write, got %d loads", lists)
+		}
+	})
+
+	t.Run("should read from the storage when redis is down", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		cached, _ := newTestCachedStorage(t, NewRedisCache(client, time.Minute))
+		server.Close()
+
+		// Act
+		product, err := cached.Get(ctx, 1)
+
+		// Assert
+		if err != nil || product.ID != 1 {
+			t.Fatalf("expected product 1, got %v, %v", product, err)
+		}
+		if errors := testutil.ToFloat64(cached.requests.WithLabelValues("Get", "error")); errors != 1 {
+			t.Errorf("expected 1 cache error, got %v", errors)
+		}
+	})
+}
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should evict the least recently used entry", func(t *testing.T) {
+		// Arrange
+		cache := NewMemoryCache(2, time.Minute)
+		_ = cache.Set(ctx, "a", []byte("a"))
+		_ = cache.Set(ctx, "b", []byte("b"))
+		_, _, _ = cache.Get(ctx, "a")
+
+		// Act
+		_ = cache.Set(ctx, "c", []byte("c"))
+
+		// Assert
+		if _, found, _ := cache.Get(ctx, "b"); found {
+			t.Errorf("expected b to be evicted")
+		}
+		if _, found, _ := cache.Get(ctx, "a"); !found {
+			t.Errorf("expected a to be kept")
+		}
+	})
+
+	t.Run("should expire entries after the ttl", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		cache := NewMemoryCache(2, time.Minute)
+		cache.now = func() time.Time { return now }
+		_ = cache.Set(ctx, "a", []byte("a"))
+
+		// Act
+		now = now.Add(time.Minute)
+		_, found, _ := cache.Get(ctx, "a")
+
+		// Assert
+		if found {
+			t.Errorf("expected a to be expired")
+		}
+	})
+}
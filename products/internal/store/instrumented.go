@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"time"
+)
+
+// InstrumentedProductStorage records the latency of every operation of the wrapped storage,
+// and logs the operations at debug level.
+type InstrumentedProductStorage struct {
+	next     ProductStorage
+	duration *prometheus.HistogramVec
+	logger   *zap.SugaredLogger
+}
+
+// NewInstrumentedStorage wraps the products of storage, registering the latency histogram with registerer.
+func NewInstrumentedStorage(storage Storage, registerer prometheus.Registerer, logger *zap.SugaredLogger) (Storage, error) {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "store_operation_duration_seconds",
+		Help:    "Store operation latency by method.",
+		Buckets: []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5},
+	}, []string{"method"})
+
+	if err := registerer.Register(duration); err != nil {
+		return storage, err
+	}
+
+	storage.Products = &InstrumentedProductStorage{next: storage.Products, duration: duration, logger: logger}
+
+	return storage, nil
+}
+
+func (s *InstrumentedProductStorage) observe(method string, start time.Time) {
+	elapsed := time.Since(start)
+	s.duration.WithLabelValues(method).Observe(elapsed.Seconds())
+	s.logger.Debugw("store operation completed", "method", method, "duration", elapsed)
+}
+
+func (s *InstrumentedProductStorage) Create(ctx context.Context, product *Product) error {
+	defer s.observe("Create", time.Now())
+	return s.next.Create(ctx, product)
+}
+
+func (s *InstrumentedProductStorage) List(ctx context.Context, query ListProductsQuery) (PaginatedResponse, error) {
+	defer s.observe("List", time.Now())
+	return s.next.List(ctx, query)
+}
+
+func (s *InstrumentedProductStorage) Get(ctx context.Context, id int64) (*Product, error) {
+	defer s.observe("Get", time.Now())
+	return s.next.Get(ctx, id)
+}
+
+func (s *InstrumentedProductStorage) GetMany(ctx context.Context, ids []int64) ([]*Product, error) {
+	defer s.observe("GetMany", time.Now())
+	return s.next.GetMany(ctx, ids)
+}
+
+func (s *InstrumentedProductStorage) GetBySlug(ctx context.Context, slug string) (*Product, error) {
+	defer s.observe("GetBySlug", time.Now())
+	return s.next.GetBySlug(ctx, slug)
+}
+
+func (s *InstrumentedProductStorage) Update(ctx context.Context, id int64, updatedProduct *Product) (*Product, error) {
+	defer s.observe("Update", time.Now())
+	return s.next.Update(ctx, id, updatedProduct)
+}
+
+func (s *InstrumentedProductStorage) Delete(ctx context.Context, id int64) error {
+	defer s.observe("Delete", time.Now())
+	return s.next.Delete(ctx, id)
+}
+
+func (s *InstrumentedProductStorage) SetTranslation(ctx context.Context, id int64, locale string, translation ProductTranslation) (*Product, error) {
+	defer s.observe("SetTranslation", time.Now())
+	return s.next.SetTranslation(ctx, id, locale, translation)
+}
+
+func (s *InstrumentedProductStorage) DeleteTranslation(ctx context.Context, id int64, locale string) error {
+	defer s.observe("DeleteTranslation", time.Now())
+	return s.next.DeleteTranslation(ctx, id, locale)
+}
+
+func (s *InstrumentedProductStorage) Transition(ctx context.Context, id int64, status Status) (*Product, error) {
+	defer s.observe("Transition", time.Now())
+	return s.next.Transition(ctx, id, status)
+}
+
+func (s *InstrumentedProductStorage) Schedule(ctx context.Context, id int64, publishAt, unpublishAt *time.Time) (*Product, error) {
+	defer s.observe("Schedule", time.Now())
+	return s.next.Schedule(ctx, id, publishAt, unpublishAt)
+}
+
+func (s *InstrumentedProductStorage) ApplySchedule(ctx context.Context, now time.Time) ([]*Product, error) {
+	defer s.observe("ApplySchedule", time.Now())
+	return s.next.ApplySchedule(ctx, now)
+}
+
+func (s *InstrumentedProductStorage) Ping(ctx context.Context) error {
+	defer s.observe("Ping", time.Now())
+	return s.next.Ping(ctx)
+}
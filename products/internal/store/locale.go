@@ -0,0 +1,145 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ProductTranslation struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+}
+
+type TranslationNotFoundError struct {
+	ID     int64
+	Locale string
+}
+
+func (e *TranslationNotFoundError) Error() string {
+	return fmt.Sprintf("translation %v for product with id %v not found", e.Locale, e.ID)
+}
+
+// NormalizeLocale returns the canonical casing of a language tag, e.g. "de_at" becomes "de-AT".
+func NormalizeLocale(locale string) string {
+	subtags := strings.Split(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	for i, subtag := range subtags {
+		switch {
+		case i == 0:
+			subtags[i] = strings.ToLower(subtag)
+		case len(subtag) == 2:
+			subtags[i] = strings.ToUpper(subtag)
+		case len(subtag) == 4:
+			subtags[i] = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		default:
+			subtags[i] = strings.ToLower(subtag)
+		}
+	}
+
+	return strings.Join(subtags, "-")
+}
+
+// FallbackChain expands the preferred locales into the ordered list of locales to try,
+// e.g. ["de-AT", "fr"] becomes ["de-AT", "de", "fr"]. The untranslated product fields
+// are the implicit last step of every chain.
+func FallbackChain(locales ...string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+
+	for _, locale := range locales {
+		subtags := strings.Split(NormalizeLocale(locale), "-")
+		for i := len(subtags); i > 0; i-- {
+			candidate := strings.Join(subtags[:i], "-")
+			if candidate == "" || seen[candidate] {
+				continue
+			}
+			seen[candidate] = true
+			chain = append(chain, candidate)
+		}
+	}
+
+	return chain
+}
+
+// ParseLocales returns the fallback chain requested by the client. The locale query
+// parameter takes precedence over the Accept-Language header.
+func ParseLocales(r *http.Request) []string {
+	if locale := r.URL.Query().Get("locale"); locale != "" {
+		return FallbackChain(locale)
+	}
+
+	return FallbackChain(parseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+}
+
+func parseAcceptLanguage(header string) []string {
+	type weightedLocale struct {
+		locale string
+		weight float64
+	}
+
+	var weighted []weightedLocale
+	for _, part := range strings.Split(header, ",") {
+		locale, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		locale = strings.TrimSpace(locale)
+		if locale == "" || locale == "*" {
+			continue
+		}
+
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		weighted = append(weighted, weightedLocale{locale: locale, weight: weight})
+	}
+
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].weight > weighted[j].weight
+	})
+
+	locales := make([]string, 0, len(weighted))
+	for _, w := range weighted {
+		locales = append(locales, w.locale)
+	}
+
+	return locales
+}
+
+// Localize returns a copy of the product with Name, Description and Category taken from
+// the first translation in the chain. Fields missing from the translation keep their
+// default value.
+func (p *Product) Localize(chain []string) *Product {
+	localized := p.clone()
+	localized.Translations = nil
+
+	for _, locale := range chain {
+		translation, ok := p.Translations[locale]
+		if !ok {
+			continue
+		}
+
+		localized.Locale = locale
+		if translation.Name != "" {
+			localized.Name = translation.Name
+		}
+		if translation.Description != "" {
+			localized.Description = translation.Description
+		}
+		if translation.Category != "" {
+			localized.Category = translation.Category
+		}
+		break
+	}
+
+	return localized
+}
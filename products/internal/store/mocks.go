@@ -1,8 +1,8 @@
 package store
 
 import (
+	"context"
 	"fmt"
-	"strings"
 	"sync"
 	"time"
 )
@@ -16,14 +16,16 @@ func NewMockStorage() Storage {
 func NewMockProductStorage() *MockProductStore {
 	store := &MockProductStore{
 		products: make([]*Product, 0),
+		slugs:    make(slugIndex),
 		nextID:   1,
 	}
 
 	for i := 1; i <= 10; i++ {
-		_ = store.Create(&Product{
+		_ = store.Create(context.Background(), &Product{
 			Name:        fmt.Sprintf("Product %d", i),
 			Description: fmt.Sprintf("Description for product %d", i),
 			Category:    fmt.Sprintf("Category %d", i),
+			Status:      Published,
 		})
 	}
 
@@ -33,10 +35,11 @@ func NewMockProductStorage() *MockProductStore {
 type MockProductStore struct {
 	sync.Mutex
 	products []*Product
+	slugs    slugIndex
 	nextID   int64
 }
 
-func (s *MockProductStore) Create(product *Product) error {
+func (s *MockProductStore) Create(ctx context.Context, product *Product) error {
 	s.Lock()
 	defer s.Unlock()
 
@@ -46,60 +49,68 @@ func (s *MockProductStore) Create(product *Product) error {
 	currentTime := time.Now().Format(time.RFC3339)
 	product.CreatedAt = currentTime
 	product.UpdatedAt = currentTime
+	product.Version = 1
+	if product.Status == "" {
+		product.Status = Draft
+	}
+	s.slugs.assign(product)
 
-	s.products = append(s.products, product)
+	s.products = append(s.products, product.clone())
 	return nil
 }
 
-func (s *MockProductStore) List(query ListProductsQuery) (PaginatedResponse, error) {
+func (s *MockProductStore) List(ctx context.Context, query ListProductsQuery) (PaginatedResponse, error) {
 	s.Lock()
 	defer s.Unlock()
 
-	start := (query.Page - 1) * query.Limit
-	end := start + query.Limit
-	if end > len(s.products) {
-		end = len(s.products)
-	}
+	return listProducts(s.products, query), nil
+}
 
-	if query.Order == DESC {
-		start, end = len(s.products)-end, len(s.products)-start
-	}
+func (s *MockProductStore) Get(ctx context.Context, id int64) (*Product, error) {
+	s.Lock()
+	defer s.Unlock()
 
-	filtered := filter(s.products, func(product *Product) bool {
-		if query.Search != "" && !strings.Contains(product.Name, query.Search) {
-			return false
-		}
-		if len(query.Category) > 0 && !contains(query.Category, product.Category) {
-			return false
-		}
-		return true
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
 	})
 
-	return PaginatedResponse{
-		Limit: query.Limit,
-		Page:  query.Page,
-		Order: query.Order,
-		Total: len(s.products),
-		Data:  filtered[start:end],
-	}, nil
+	if !exists {
+		return nil, &ProductNotFoundError{ID: id}
+	}
+
+	return product.clone(), nil
 }
 
-func (s *MockProductStore) Get(id int64) (*Product, error) {
+func (s *MockProductStore) GetMany(ctx context.Context, ids []int64) ([]*Product, error) {
 	s.Lock()
 	defer s.Unlock()
 
+	return getMany(s.products, ids), nil
+}
+
+// GetBySlug returns the product owning the slug. The returned product's Slug differs
+// from the requested one when the slug belongs to the product's history.
+func (s *MockProductStore) GetBySlug(ctx context.Context, slug string) (*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	id, exists := s.slugs.lookup(slug)
+	if !exists {
+		return nil, &SlugNotFoundError{Slug: slug}
+	}
+
 	product, exists := find(s.products, func(product *Product) bool {
 		return product.ID == id
 	})
 
 	if !exists {
-		return nil, &ProductNotFoundError{ID: id}
+		return nil, &SlugNotFoundError{Slug: slug}
 	}
 
-	return product, nil
+	return product.clone(), nil
 }
 
-func (s *MockProductStore) Update(id int64, updatedProduct *Product) (*Product, error) {
+func (s *MockProductStore) Update(ctx context.Context, id int64, updatedProduct *Product) (*Product, error) {
 	s.Lock()
 	defer s.Unlock()
 
@@ -114,12 +125,109 @@ func (s *MockProductStore) Update(id int64, updatedProduct *Product) (*Product,
 	product.Name = updatedProduct.Name
 	product.Description = updatedProduct.Description
 	product.Category = updatedProduct.Category
-	product.UpdatedAt = time.Now().Format(time.RFC3339)
+	touch(product, time.Now())
+	s.slugs.assign(product)
+
+	return product.clone(), nil
+}
+
+func (s *MockProductStore) SetTranslation(ctx context.Context, id int64, locale string, translation ProductTranslation) (*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
+	})
+
+	if !exists {
+		return nil, &ProductNotFoundError{ID: id}
+	}
+
+	if product.Translations == nil {
+		product.Translations = make(map[string]ProductTranslation)
+	}
+	product.Translations[locale] = translation
+	touch(product, time.Now())
+
+	return product.clone(), nil
+}
+
+func (s *MockProductStore) DeleteTranslation(ctx context.Context, id int64, locale string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
+	})
+
+	if !exists {
+		return &ProductNotFoundError{ID: id}
+	}
+
+	if _, exists := product.Translations[locale]; !exists {
+		return &TranslationNotFoundError{ID: id, Locale: locale}
+	}
+
+	delete(product.Translations, locale)
+	touch(product, time.Now())
+
+	return nil
+}
+
+func (s *MockProductStore) Transition(ctx context.Context, id int64, status Status) (*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
+	})
+
+	if !exists {
+		return nil, &ProductNotFoundError{ID: id}
+	}
+
+	if err := transition(product, status, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return product.clone(), nil
+}
+
+func (s *MockProductStore) Schedule(ctx context.Context, id int64, publishAt, unpublishAt *time.Time) (*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
+	})
+
+	if !exists {
+		return nil, &ProductNotFoundError{ID: id}
+	}
+
+	if err := checkSchedule(product, publishAt, unpublishAt); err != nil {
+		return nil, err
+	}
+
+	product.PublishAt = publishAt
+	product.UnpublishAt = unpublishAt
+	touch(product, time.Now())
+
+	return product.clone(), nil
+}
+
+func (s *MockProductStore) ApplySchedule(ctx context.Context, now time.Time) ([]*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return applySchedule(s.products, now), nil
+}
 
-	return product, nil
+func (s *MockProductStore) Ping(ctx context.Context) error {
+	return ctx.Err()
 }
 
-func (s *MockProductStore) Delete(id int64) error {
+func (s *MockProductStore) Delete(ctx context.Context, id int64) error {
 	s.Lock()
 	defer s.Unlock()
 
@@ -134,6 +242,7 @@ func (s *MockProductStore) Delete(id int64) error {
 	s.products = remove(s.products, func(product *Product) bool {
 		return product.ID == id
 	})
+	s.slugs.release(id)
 
 	return nil
 }
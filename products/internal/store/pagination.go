@@ -1,7 +1,6 @@
 package store
 
 import (
-	"github.com/go-chi/chi/v5"
 	"net/http"
 	"strconv"
 )
@@ -32,6 +31,8 @@ type ListProductsQuery struct {
 	PaginatedQuery `json:",inline"`
 	Search         string   `json:"search"`
 	Category       []string `json:"category"`
+	Locales        []string `json:"locales"`
+	Status         []Status `json:"status" validate:"dive,oneof=draft review published archived"`
 }
 
 func (q *ListProductsQuery) GetNextURL(r *http.Request) string {
@@ -50,12 +51,16 @@ func ParseListProductsQuery(r *http.Request) (ListProductsQuery, error) {
 	query.PaginatedQuery = paginatedQuery
 	query.Search = r.URL.Query().Get("search")
 	query.Category = r.URL.Query()["category"]
+	query.Locales = ParseLocales(r)
+	for _, status := range r.URL.Query()["status"] {
+		query.Status = append(query.Status, Status(status))
+	}
 
 	return query, nil
 }
 
 func ParsePaginatedQuery(r *http.Request) (PaginatedQuery, error) {
-	limitParam := chi.URLParam(r, "limit")
+	limitParam := r.URL.Query().Get("limit")
 	if limitParam == "" {
 		limitParam = "10"
 	}
@@ -64,7 +69,7 @@ func ParsePaginatedQuery(r *http.Request) (PaginatedQuery, error) {
 		return PaginatedQuery{}, err
 	}
 
-	pageParam := chi.URLParam(r, "page")
+	pageParam := r.URL.Query().Get("page")
 	if pageParam == "" {
 		pageParam = "1"
 	}
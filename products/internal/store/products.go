@@ -1,36 +1,72 @@
 package store
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
 type Product struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Category    string `json:"category"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID           int64                         `json:"id"`
+	Slug         string                        `json:"slug"`
+	Name         string                        `json:"name"`
+	Description  string                        `json:"description"`
+	Category     string                        `json:"category"`
+	Locale       string                        `json:"locale,omitempty"`
+	Translations map[string]ProductTranslation `json:"translations,omitempty"`
+	Status       Status                        `json:"status"`
+	PublishAt    *time.Time                    `json:"publish_at,omitempty"`
+	UnpublishAt  *time.Time                    `json:"unpublish_at,omitempty"`
+	CreatedAt    string                        `json:"created_at"`
+	UpdatedAt    string                        `json:"updated_at"`
+	Version      int64                         `json:"version"`
+}
+
+// touch records a change of the product. Version starts at 1 and increases with every
+// change, so it identifies the state of the product in ETags.
+func touch(product *Product, now time.Time) {
+	product.UpdatedAt = now.Format(time.RFC3339)
+	product.Version++
+}
+
+// clone returns a deep copy of the product. Stores return clones, made while holding
+// their lock, so callers can read the product while later writes change the original.
+func (p *Product) clone() *Product {
+	cloned := *p
+	cloned.Translations = maps.Clone(p.Translations)
+	if p.PublishAt != nil {
+		publishAt := *p.PublishAt
+		cloned.PublishAt = &publishAt
+	}
+	if p.UnpublishAt != nil {
+		unpublishAt := *p.UnpublishAt
+		cloned.UnpublishAt = &unpublishAt
+	}
+
+	return &cloned
 }
 
 // ProductStore TODO: Change implementation to use a database
 type ProductStore struct {
 	sync.Mutex
 	products []*Product
+	slugs    slugIndex
 	nextID   int64
 }
 
 func NewProductStore() *ProductStore {
 	return &ProductStore{
 		products: make([]*Product, 0),
+		slugs:    make(slugIndex),
 		nextID:   1,
 	}
 }
 
-func (s *ProductStore) Create(product *Product) error {
+func (s *ProductStore) Create(ctx context.Context, product *Product) error {
 	s.Lock()
 	defer s.Unlock()
 
@@ -40,43 +76,22 @@ func (s *ProductStore) Create(product *Product) error {
 	currentTime := time.Now().Format(time.RFC3339)
 	product.CreatedAt = currentTime
 	product.UpdatedAt = currentTime
+	product.Version = 1
+	if product.Status == "" {
+		product.Status = Draft
+	}
+	s.slugs.assign(product)
 
-	s.products = append(s.products, product)
+	s.products = append(s.products, product.clone())
 	return nil
 }
 
 // List TODO: Add support for sorting
-func (s *ProductStore) List(query ListProductsQuery) (PaginatedResponse, error) {
+func (s *ProductStore) List(ctx context.Context, query ListProductsQuery) (PaginatedResponse, error) {
 	s.Lock()
 	defer s.Unlock()
 
-	start := (query.Page - 1) * query.Limit
-	end := start + query.Limit
-	if end > len(s.products) {
-		end = len(s.products)
-	}
-
-	if query.Order == DESC {
-		start, end = len(s.products)-end, len(s.products)-start
-	}
-
-	filtered := filter(s.products, func(product *Product) bool {
-		if query.Search != "" && !strings.Contains(product.Name, query.Search) {
-			return false
-		}
-		if len(query.Category) > 0 && !contains(query.Category, product.Category) {
-			return false
-		}
-		return true
-	})
-
-	return PaginatedResponse{
-		Limit: query.Limit,
-		Page:  query.Page,
-		Order: query.Order,
-		Total: len(s.products),
-		Data:  filtered[start:end],
-	}, nil
+	return listProducts(s.products, query), nil
 }
 
 func contains(categories []string, category string) bool {
@@ -88,7 +103,7 @@ func contains(categories []string, category string) bool {
 	return false
 }
 
-func (s *ProductStore) Get(id int64) (*Product, error) {
+func (s *ProductStore) Get(ctx context.Context, id int64) (*Product, error) {
 	s.Lock()
 	defer s.Unlock()
 
@@ -100,10 +115,39 @@ func (s *ProductStore) Get(id int64) (*Product, error) {
 		return nil, &ProductNotFoundError{ID: id}
 	}
 
-	return product, nil
+	return product.clone(), nil
+}
+
+func (s *ProductStore) GetMany(ctx context.Context, ids []int64) ([]*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return getMany(s.products, ids), nil
+}
+
+// GetBySlug returns the product owning the slug. The returned product's Slug differs
+// from the requested one when the slug belongs to the product's history.
+func (s *ProductStore) GetBySlug(ctx context.Context, slug string) (*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	id, exists := s.slugs.lookup(slug)
+	if !exists {
+		return nil, &SlugNotFoundError{Slug: slug}
+	}
+
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
+	})
+
+	if !exists {
+		return nil, &SlugNotFoundError{Slug: slug}
+	}
+
+	return product.clone(), nil
 }
 
-func (s *ProductStore) Update(id int64, updatedProduct *Product) (*Product, error) {
+func (s *ProductStore) Update(ctx context.Context, id int64, updatedProduct *Product) (*Product, error) {
 	s.Lock()
 	defer s.Unlock()
 
@@ -118,12 +162,110 @@ func (s *ProductStore) Update(id int64, updatedProduct *Product) (*Product, erro
 	product.Name = updatedProduct.Name
 	product.Description = updatedProduct.Description
 	product.Category = updatedProduct.Category
-	product.UpdatedAt = time.Now().Format(time.RFC3339)
+	touch(product, time.Now())
+	s.slugs.assign(product)
+
+	return product.clone(), nil
+}
+
+func (s *ProductStore) SetTranslation(ctx context.Context, id int64, locale string, translation ProductTranslation) (*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
+	})
+
+	if !exists {
+		return nil, &ProductNotFoundError{ID: id}
+	}
+
+	if product.Translations == nil {
+		product.Translations = make(map[string]ProductTranslation)
+	}
+	product.Translations[locale] = translation
+	touch(product, time.Now())
+
+	return product.clone(), nil
+}
+
+func (s *ProductStore) DeleteTranslation(ctx context.Context, id int64, locale string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
+	})
+
+	if !exists {
+		return &ProductNotFoundError{ID: id}
+	}
+
+	if _, exists := product.Translations[locale]; !exists {
+		return &TranslationNotFoundError{ID: id, Locale: locale}
+	}
+
+	delete(product.Translations, locale)
+	touch(product, time.Now())
+
+	return nil
+}
+
+func (s *ProductStore) Transition(ctx context.Context, id int64, status Status) (*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
+	})
+
+	if !exists {
+		return nil, &ProductNotFoundError{ID: id}
+	}
+
+	if err := transition(product, status, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return product.clone(), nil
+}
+
+func (s *ProductStore) Schedule(ctx context.Context, id int64, publishAt, unpublishAt *time.Time) (*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	product, exists := find(s.products, func(product *Product) bool {
+		return product.ID == id
+	})
+
+	if !exists {
+		return nil, &ProductNotFoundError{ID: id}
+	}
 
-	return product, nil
+	if err := checkSchedule(product, publishAt, unpublishAt); err != nil {
+		return nil, err
+	}
+
+	product.PublishAt = publishAt
+	product.UnpublishAt = unpublishAt
+	touch(product, time.Now())
+
+	return product.clone(), nil
 }
 
-func (s *ProductStore) Delete(id int64) error {
+func (s *ProductStore) ApplySchedule(ctx context.Context, now time.Time) ([]*Product, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return applySchedule(s.products, now), nil
+}
+
+// Ping succeeds as long as ctx is alive, as the products are kept in memory.
+func (s *ProductStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (s *ProductStore) Delete(ctx context.Context, id int64) error {
 	s.Lock()
 	defer s.Unlock()
 
@@ -138,19 +280,59 @@ func (s *ProductStore) Delete(id int64) error {
 	s.products = remove(s.products, func(product *Product) bool {
 		return product.ID == id
 	})
+	s.slugs.release(id)
 
 	return nil
 }
 
-func filter(products []*Product, predicate func(product *Product) bool) []*Product {
-	var filtered []*Product
+// listProducts filters the products in the requested locale and returns the requested page.
+func listProducts(products []*Product, query ListProductsQuery) PaginatedResponse {
+	filtered := make([]*Product, 0, len(products))
 	for _, product := range products {
-		if predicate(product) {
-			filtered = append(filtered, product)
+		localized := product.Localize(query.Locales)
+		if query.Search != "" && !strings.Contains(localized.Name, query.Search) {
+			continue
 		}
+		if len(query.Category) > 0 && !contains(query.Category, localized.Category) {
+			continue
+		}
+		if len(query.Status) > 0 && !slices.Contains(query.Status, product.Status) {
+			continue
+		}
+		filtered = append(filtered, localized)
 	}
 
-	return filtered
+	if query.Order == DESC {
+		slices.Reverse(filtered)
+	}
+
+	start := max((query.Page-1)*query.Limit, 0)
+	start = min(start, len(filtered))
+	end := min(start+query.Limit, len(filtered))
+
+	return PaginatedResponse{
+		Limit: query.Limit,
+		Page:  query.Page,
+		Order: query.Order,
+		Total: len(filtered),
+		Data:  filtered[start:end],
+	}
+}
+
+func getMany(products []*Product, ids []int64) []*Product {
+	byID := make(map[int64]*Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	found := make([]*Product, 0, len(ids))
+	for _, id := range ids {
+		if product, ok := byID[id]; ok {
+			found = append(found, product.clone())
+		}
+	}
+
+	return found
 }
 
 func find(products []*Product, predicate func(product *Product) bool) (*Product, bool) {
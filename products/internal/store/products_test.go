@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestProductStorage(t *testing.T) {
+	ctx := context.Background()
+	storages := map[string]ProductStorage{
+		"memory": NewProductStore(),
+		"mock":   NewMockProductStorage(),
+	}
+
+	for name, storage := range storages {
+		t.Run(fmt.Sprintf("should return copies unaffected by later writes (%s)", name), func(t *testing.T) {
+			// Arrange
+			product := &Product{Name: "Lamp", Description: "Description", Category: "Home"}
+			if err := storage.Create(ctx, product); err != nil {
+				t.Fatal(err)
+			}
+			read, _ := storage.Get(ctx, product.ID)
+
+			// Act
+			var wg sync.WaitGroup
+			for i := range 50 {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					_, _ = storage.SetTranslation(ctx, product.ID, fmt.Sprintf("l%d", i), ProductTranslation{Name: "Lampe"})
+				}()
+				go func() {
+					defer wg.Done()
+					got, _ := storage.Get(ctx, product.ID)
+					many, _ := storage.GetMany(ctx, []int64{product.ID})
+					_, _ = json.Marshal(got.Translations)
+					_ = many[0].Localize([]string{"l1"})
+				}()
+			}
+			wg.Wait()
+			product.Name = "Renamed"
+
+			// Assert
+			if len(read.Translations) != 0 {
+				t.Errorf("expected the earlier read to keep no translations, got %v", read.Translations)
+			}
+			if got, _ := storage.Get(ctx, product.ID); got.Name != "Lamp" || len(got.Translations) != 50 {
+				t.Errorf("expected the stored product with 50 translations, got %q with %d", got.Name, len(got.Translations))
+			}
+		})
+	}
+}
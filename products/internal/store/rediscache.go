@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+const (
+	redisCachePrefix   = "products:cache:"
+	redisGenerationKey = redisCachePrefix + "generation"
+)
+
+// RedisCache shares the cache between replicas, so a write on one replica
+// invalidates the entries cached by the others.
+type RedisCache struct {
+	client     redis.UniversalClient
+	ownsClient bool
+	ttl        time.Duration
+}
+
+func NewRedisCache(client redis.UniversalClient, ttl time.Duration) *RedisCache {
+	return &RedisCache{client: client, ttl: ttl}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	value, err := c.client.Get(ctx, redisCachePrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	return c.client.Set(ctx, redisCachePrefix+key, value, c.ttl).Err()
+}
+
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = redisCachePrefix + key
+	}
+
+	return c.client.Del(ctx, prefixed...).Err()
+}
+
+func (c *RedisCache) Generation(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	generation, err := c.client.Get(ctx, redisGenerationKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return generation, err
+}
+
+func (c *RedisCache) NextGeneration(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	return c.client.Incr(ctx, redisGenerationKey).Err()
+}
+
+// CheckHealth makes the cache a readiness check.
+func (c *RedisCache) CheckHealth(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Close closes the client when the cache created it.
+func (c *RedisCache) Close() error {
+	if c.ownsClient {
+		return c.client.Close()
+	}
+
+	return nil
+}
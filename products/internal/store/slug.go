@@ -0,0 +1,95 @@
+package store
+
+import (
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+type SlugNotFoundError struct {
+	Slug string
+}
+
+func (e *SlugNotFoundError) Error() string {
+	return fmt.Sprintf("product with slug %v not found", e.Slug)
+}
+
+// Slugify turns a product name into a URL friendly slug, e.g. "Crème Brûlée 2" becomes "creme-brulee-2".
+func Slugify(name string) string {
+	var builder strings.Builder
+	separate := false
+
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if separate && builder.Len() > 0 {
+				builder.WriteByte('-')
+			}
+			builder.WriteRune(r)
+			separate = false
+		default:
+			separate = true
+		}
+	}
+
+	if builder.Len() == 0 {
+		return "product"
+	}
+
+	return builder.String()
+}
+
+// slugIndex maps every current and former slug to the id of the product owning it, so
+// that slugs stay unique across renames and old slugs can be redirected.
+type slugIndex map[string]int64
+
+// assign gives the product a slug derived from its name. A product keeps its current
+// slug while the name still produces it, i.e. the slug is the base or a numbered base
+// another product owns, and may take back any of its former slugs. Former slugs stay in
+// the index for redirects.
+func (index slugIndex) assign(product *Product) {
+	base := Slugify(product.Name)
+	if product.Slug == base && product.Slug != "" {
+		return
+	}
+	if owner, taken := index[base]; taken && owner != product.ID && hasNumericSuffix(product.Slug, base) {
+		return
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		owner, taken := index[slug]
+		if !taken || owner == product.ID {
+			break
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	index[slug] = product.ID
+	product.Slug = slug
+}
+
+func (index slugIndex) lookup(slug string) (int64, bool) {
+	id, exists := index[slug]
+	return id, exists
+}
+
+func (index slugIndex) release(id int64) {
+	for slug, owner := range index {
+		if owner == id {
+			delete(index, slug)
+		}
+	}
+}
+
+func hasNumericSuffix(slug, base string) bool {
+	suffix, ok := strings.CutPrefix(slug, base+"-")
+	if !ok || suffix == "" {
+		return false
+	}
+
+	return strings.Trim(suffix, "0123456789") == ""
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+type Status string
+
+const (
+	Draft     Status = "draft"
+	Review    Status = "review"
+	Published Status = "published"
+	Archived  Status = "archived"
+)
+
+// transitions lists the statuses a product may move to from each status.
+var transitions = map[Status][]Status{
+	Draft:     {Review},
+	Review:    {Draft, Published},
+	Published: {Archived},
+	Archived:  {Draft},
+}
+
+func (s Status) CanTransitionTo(next Status) bool {
+	return slices.Contains(transitions[s], next)
+}
+
+type InvalidTransitionError struct {
+	ID   int64
+	From Status
+	To   Status
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("product with id %v cannot transition from %v to %v", e.ID, e.From, e.To)
+}
+
+func transition(product *Product, next Status, now time.Time) error {
+	if !product.Status.CanTransitionTo(next) {
+		return &InvalidTransitionError{ID: product.ID, From: product.Status, To: next}
+	}
+
+	product.Status = next
+	touch(product, now)
+
+	return nil
+}
+
+// InvalidScheduleError is returned for a schedule the product would never reach: only
+// products in review are published, and only published products are archived.
+type InvalidScheduleError struct {
+	ID     int64
+	Status Status
+	Field  string
+}
+
+func (e *InvalidScheduleError) Error() string {
+	return fmt.Sprintf("product with id %v is %v and cannot be scheduled with %v", e.ID, e.Status, e.Field)
+}
+
+// checkSchedule accepts publishAt for a product in review, and unpublishAt for a
+// published product or a product in review scheduled to be published.
+func checkSchedule(product *Product, publishAt, unpublishAt *time.Time) error {
+	if publishAt != nil && product.Status != Review {
+		return &InvalidScheduleError{ID: product.ID, Status: product.Status, Field: "publish_at"}
+	}
+	if unpublishAt != nil && product.Status != Published && publishAt == nil {
+		return &InvalidScheduleError{ID: product.ID, Status: product.Status, Field: "unpublish_at"}
+	}
+
+	return nil
+}
+
+// applySchedule publishes products in review whose PublishAt has passed and archives
+// published products whose UnpublishAt has passed. It returns the products it changed.
+func applySchedule(products []*Product, now time.Time) []*Product {
+	var changed []*Product
+	for _, product := range products {
+		transitioned := false
+
+		if product.Status == Review && product.PublishAt != nil && !product.PublishAt.After(now) {
+			_ = transition(product, Published, now)
+			product.PublishAt = nil
+			transitioned = true
+		}
+
+		if product.Status == Published && product.UnpublishAt != nil && !product.UnpublishAt.After(now) {
+			_ = transition(product, Archived, now)
+			product.UnpublishAt = nil
+			transitioned = true
+		}
+
+		if transitioned {
+			changed = append(changed, product.clone())
+		}
+	}
+
+	return changed
+}
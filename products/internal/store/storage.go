@@ -1,13 +1,37 @@
 package store
 
+import (
+	"context"
+	"time"
+)
+
+// ProductStorage is implemented by ProductStore, MockProductStore and the decorators
+// wrapping them.
+type ProductStorage interface {
+	Create(ctx context.Context, product *Product) error
+	List(ctx context.Context, query ListProductsQuery) (PaginatedResponse, error)
+	Get(ctx context.Context, id int64) (*Product, error)
+	// GetMany returns the products with the ids in the order of ids, skipping unknown ids.
+	GetMany(ctx context.Context, ids []int64) ([]*Product, error)
+	GetBySlug(ctx context.Context, slug string) (*Product, error)
+	Update(ctx context.Context, id int64, updatedProduct *Product) (*Product, error)
+	Delete(ctx context.Context, id int64) error
+	SetTranslation(ctx context.Context, id int64, locale string, translation ProductTranslation) (*Product, error)
+	DeleteTranslation(ctx context.Context, id int64, locale string) error
+	Transition(ctx context.Context, id int64, status Status) (*Product, error)
+	Schedule(ctx context.Context, id int64, publishAt, unpublishAt *time.Time) (*Product, error)
+	ApplySchedule(ctx context.Context, now time.Time) ([]*Product, error)
+	// Ping reports whether the storage can serve requests.
+	Ping(ctx context.Context) error
+}
+
 type Storage struct {
-	Products interface {
-		Create(product *Product) error
-		List(query ListProductsQuery) (PaginatedResponse, error)
-		Get(id int64) (*Product, error)
-		Update(id int64, updatedProduct *Product) (*Product, error)
-		Delete(id int64) error
-	}
+	Products ProductStorage
+}
+
+// CheckHealth makes Storage a readiness check.
+func (s Storage) CheckHealth(ctx context.Context) error {
+	return s.Products.Ping(ctx)
 }
 
 func NewStorage() Storage {
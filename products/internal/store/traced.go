@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const tracerName = "github.com/dawidpereira/online-store-go/products/internal/store"
+
+// TracedProductStorage starts a span for every operation of the wrapped storage.
+type TracedProductStorage struct {
+	next   ProductStorage
+	tracer trace.Tracer
+}
+
+// NewTracedStorage wraps the products of storage with spans created by provider.
+func NewTracedStorage(storage Storage, provider trace.TracerProvider) Storage {
+	storage.Products = &TracedProductStorage{next: storage.Products, tracer: provider.Tracer(tracerName)}
+
+	return storage
+}
+
+func (s *TracedProductStorage) start(ctx context.Context, method string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "ProductStorage."+method, trace.WithAttributes(attributes...))
+}
+
+// end records err on the span, unless it is an expected not found error.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !isNotFound(err) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func isNotFound(err error) bool {
+	var productNotFound *ProductNotFoundError
+	var slugNotFound *SlugNotFoundError
+	var translationNotFound *TranslationNotFoundError
+
+	return errors.As(err, &productNotFound) || errors.As(err, &slugNotFound) || errors.As(err, &translationNotFound)
+}
+
+func (s *TracedProductStorage) Create(ctx context.Context, product *Product) error {
+	ctx, span := s.start(ctx, "Create")
+	err := s.next.Create(ctx, product)
+	end(span, err)
+
+	return err
+}
+
+func (s *TracedProductStorage) List(ctx context.Context, query ListProductsQuery) (PaginatedResponse, error) {
+	ctx, span := s.start(ctx, "List", attribute.Int("query.page", query.Page), attribute.Int("query.limit", query.Limit))
+	result, err := s.next.List(ctx, query)
+	end(span, err)
+
+	return result, err
+}
+
+func (s *TracedProductStorage) Get(ctx context.Context, id int64) (*Product, error) {
+	ctx, span := s.start(ctx, "Get", attribute.Int64("product.id", id))
+	result, err := s.next.Get(ctx, id)
+	end(span, err)
+
+	return result, err
+}
+
+func (s *TracedProductStorage) GetMany(ctx context.Context, ids []int64) ([]*Product, error) {
+	ctx, span := s.start(ctx, "GetMany", attribute.Int("product.count", len(ids)))
+	result, err := s.next.GetMany(ctx, ids)
+	end(span, err)
+
+	return result, err
+}
+
+func (s *TracedProductStorage) GetBySlug(ctx context.Context, slug string) (*Product, error) {
+	ctx, span := s.start(ctx, "GetBySlug", attribute.String("product.slug", slug))
+	result, err := s.next.GetBySlug(ctx, slug)
+	end(span, err)
+
+	return result, err
+}
+
+func (s *TracedProductStorage) Update(ctx context.Context, id int64, updatedProduct *Product) (*Product, error) {
+	ctx, span := s.start(ctx, "Update", attribute.Int64("product.id", id))
+	result, err := s.next.Update(ctx, id, updatedProduct)
+	end(span, err)
+
+	return result, err
+}
+
+func (s *TracedProductStorage) Delete(ctx context.Context, id int64) error {
+	ctx, span := s.start(ctx, "Delete", attribute.Int64("product.id", id))
+	err := s.next.Delete(ctx, id)
+	end(span, err)
+
+	return err
+}
+
+func (s *TracedProductStorage) SetTranslation(ctx context.Context, id int64, locale string, translation ProductTranslation) (*Product, error) {
+	ctx, span := s.start(ctx, "SetTranslation", attribute.Int64("product.id", id))
+	result, err := s.next.SetTranslation(ctx, id, locale, translation)
+	end(span, err)
+
+	return result, err
+}
+
+func (s *TracedProductStorage) DeleteTranslation(ctx context.Context, id int64, locale string) error {
+	ctx, span := s.start(ctx, "DeleteTranslation", attribute.Int64("product.id", id))
+	err := s.next.DeleteTranslation(ctx, id, locale)
+	end(span, err)
+
+	return err
+}
+
+func (s *TracedProductStorage) Transition(ctx context.Context, id int64, status Status) (*Product, error) {
+	ctx, span := s.start(ctx, "Transition", attribute.Int64("product.id", id))
+	result, err := s.next.Transition(ctx, id, status)
+	end(span, err)
+
+	return result, err
+}
+
+func (s *TracedProductStorage) Schedule(ctx context.Context, id int64, publishAt, unpublishAt *time.Time) (*Product, error) {
+	ctx, span := s.start(ctx, "Schedule", attribute.Int64("product.id", id))
+	result, err := s.next.Schedule(ctx, id, publishAt, unpublishAt)
+	end(span, err)
+
+	return result, err
+}
+
+func (s *TracedProductStorage) ApplySchedule(ctx context.Context, now time.Time) ([]*Product, error) {
+	ctx, span := s.start(ctx, "ApplySchedule")
+	result, err := s.next.ApplySchedule(ctx, now)
+	end(span, err)
+
+	return result, err
+}
+
+func (s *TracedProductStorage) Ping(ctx context.Context) error {
+	ctx, span := s.start(ctx, "Ping")
+	err := s.next.Ping(ctx)
+	end(span, err)
+
+	return err
+}
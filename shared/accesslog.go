@@ -0,0 +1,174 @@
+package shared
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type AccessLogConfig struct {
+	// SampleRate is the share of successful requests logged. Requests failing with a
+	// 4xx or 5xx status are always logged.
+	SampleRate float64 `env:"SAMPLE_RATE" default:"1"`
+	// Headers lists the request headers added to every access log entry.
+	Headers []string `env:"HEADERS"`
+	// RedactHeaders are logged as [REDACTED] when listed in Headers.
+	RedactHeaders []string `env:"REDACT_HEADERS" default:"Authorization,Cookie,X-API-Key"`
+	// RedactFields are query parameters and log fields whose values are replaced, e.g.
+	// when a handler logs them through the request logger.
+	RedactFields []string `env:"REDACT_FIELDS" default:"password,token,api_key"`
+}
+
+type loggerContextKey struct{}
+
+// WithLogger stores the logger of the request in ctx.
+func WithLogger(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFromContext returns the request logger stored by AccessLog, or fallback outside
+// of a request.
+func LoggerFromContext(ctx context.Context, fallback *zap.SugaredLogger) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*zap.SugaredLogger); ok {
+		return logger
+	}
+
+	return fallback
+}
+
+// AccessLog logs one entry per request and gives handlers a request logger carrying the
+// request ID, trace IDs and client IP. Install it after middleware.RequestID,
+// TracingMiddleware and ClientIPResolver.Middleware so those fields are known.
+func AccessLog(logger *zap.SugaredLogger, config AccessLogConfig) func(http.Handler) http.Handler {
+	redactHeaders := make(map[string]bool, len(config.RedactHeaders))
+	for _, header := range config.RedactHeaders {
+		redactHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+
+	redactFields := make(map[string]bool, len(config.RedactFields))
+	for _, field := range config.RedactFields {
+		redactFields[strings.ToLower(field)] = true
+	}
+
+	logger = logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{Core: core, fields: redactFields}
+	}))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestLogger := TraceLogger(r.Context(), logger).With(
+				"request_id", middleware.GetReqID(r.Context()),
+				"client_ip", clientIP(r),
+			)
+			r = r.WithContext(WithLogger(r.Context(), requestLogger))
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status < http.StatusBadRequest && rand.Float64() >= config.SampleRate {
+				return
+			}
+
+			fields := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"bytes", ww.BytesWritten(),
+				"latency", time.Since(start),
+				"user_agent", r.UserAgent(),
+			}
+			if r.URL.RawQuery != "" {
+				fields = append(fields, "query", redactQuery(r.URL.Query(), redactFields))
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				fields = append(fields, "route", rctx.RoutePattern())
+			}
+			if len(config.Headers) > 0 {
+				headers := make(map[string]string, len(config.Headers))
+				for _, header := range config.Headers {
+					header = http.CanonicalHeaderKey(header)
+					if value := r.Header.Get(header); value != "" {
+						if redactHeaders[header] {
+							value = redacted
+						}
+						headers[header] = value
+					}
+				}
+				fields = append(fields, "headers", headers)
+			}
+
+			switch {
+			case status >= http.StatusInternalServerError:
+				requestLogger.Errorw("request completed", fields...)
+			case status >= http.StatusBadRequest:
+				requestLogger.Warnw("request completed", fields...)
+			default:
+				requestLogger.Infow("request completed", fields...)
+			}
+		})
+	}
+}
+
+func redactQuery(query url.Values, fields map[string]bool) string {
+	for key, values := range query {
+		if fields[strings.ToLower(key)] {
+			for i := range values {
+				values[i] = redacted
+			}
+		}
+	}
+
+	return query.Encode()
+}
+
+// redactingCore replaces the values of the configured fields before they are encoded.
+type redactingCore struct {
+	zapcore.Core
+	fields map[string]bool
+}
+
+// redact returns fields with the values replaced, copying them as the caller owns the slice.
+func (core *redactingCore) redact(fields []zapcore.Field) []zapcore.Field {
+	copied := false
+	for i, field := range fields {
+		if !core.fields[strings.ToLower(field.Key)] {
+			continue
+		}
+		if !copied {
+			fields, copied = slices.Clone(fields), true
+		}
+		fields[i] = zap.String(field.Key, redacted)
+	}
+
+	return fields
+}
+
+func (core *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: core.Core.With(core.redact(fields)), fields: core.fields}
+}
+
+func (core *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if core.Enabled(entry.Level) {
+		return checked.AddCore(entry, core)
+	}
+
+	return checked
+}
+
+func (core *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return core.Core.Write(entry, core.redact(fields))
+}
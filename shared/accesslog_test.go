@@ -0,0 +1,114 @@
+package shared
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestAccessLog(config AccessLogConfig, handler http.HandlerFunc) (http.Handler, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(AccessLog(zap.New(core).Sugar(), config))
+	r.Get("/products/{id}", handler)
+
+	return r, logs
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Run("should log the request with its route pattern", func(t *testing.T) {
+		// Arrange
+		handler, logs := newTestAccessLog(AccessLogConfig{SampleRate: 1, RedactFields: []string{"password"}}, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("product"))
+		})
+		req := httptest.NewRequest(http.MethodGet, "/products/1?password=secret&locale=de", nil)
+		req.Header.Set("User-Agent", "test-agent")
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		// Assert
+		entries := logs.All()
+		assert.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+		assert.Equal(t, "/products/{id}", fields["route"])
+		assert.Equal(t, int64(200), fields["status"])
+		assert.Equal(t, int64(7), fields["bytes"])
+		assert.Equal(t, "test-agent", fields["user_agent"])
+		assert.Equal(t, "192.0.2.1", fields["client_ip"])
+		assert.NotEmpty(t, fields["request_id"])
+		assert.Equal(t, "locale=de&password=%5BREDACTED%5D", fields["query"])
+	})
+
+	t.Run("should always log failed requests", func(t *testing.T) {
+		// Arrange
+		handler, logs := newTestAccessLog(AccessLogConfig{SampleRate: 0}, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/1", nil))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products", nil))
+
+		// Assert
+		entries := logs.All()
+		assert.Len(t, entries, 2)
+		assert.Equal(t, zapcore.ErrorLevel, entries[0].Level)
+		assert.Equal(t, zapcore.WarnLevel, entries[1].Level)
+	})
+
+	t.Run("should skip sampled out successful requests", func(t *testing.T) {
+		// Arrange
+		handler, logs := newTestAccessLog(AccessLogConfig{SampleRate: 0}, func(w http.ResponseWriter, r *http.Request) {})
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/1", nil))
+
+		// Assert
+		assert.Equal(t, 0, logs.Len())
+	})
+
+	t.Run("should redact configured headers", func(t *testing.T) {
+		// Arrange
+		handler, logs := newTestAccessLog(AccessLogConfig{
+			SampleRate:    1,
+			Headers:       []string{"Authorization", "Accept-Language"},
+			RedactHeaders: []string{"authorization"},
+		}, func(w http.ResponseWriter, r *http.Request) {})
+		req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Accept-Language", "de")
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		// Assert
+		headers := logs.All()[0].ContextMap()["headers"]
+		assert.Equal(t, map[string]string{"Authorization": "[REDACTED]", "Accept-Language": "de"}, headers)
+	})
+
+	t.Run("should share the correlation fields with the request logger", func(t *testing.T) {
+		// Arrange
+		handler, logs := newTestAccessLog(AccessLogConfig{SampleRate: 1, RedactFields: []string{"token"}}, func(w http.ResponseWriter, r *http.Request) {
+			LoggerFromContext(r.Context(), nil).Infow("handler message", "token", "secret")
+		})
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/1", nil))
+
+		// Assert
+		entries := logs.All()
+		assert.Len(t, entries, 2)
+		assert.Equal(t, entries[1].ContextMap()["request_id"], entries[0].ContextMap()["request_id"])
+		assert.Equal(t, "[REDACTED]", entries[0].ContextMap()["token"])
+	})
+}
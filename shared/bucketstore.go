@@ -0,0 +1,144 @@
+package shared
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultMaxKeys is the number of clients a rate limiter tracks when Config.MaxKeys is not set.
+const DefaultMaxKeys = 100_000
+
+// minSweepInterval keeps the janitor from spinning when the time frame is very short.
+const minSweepInterval = time.Second
+
+type bucketEntry[T any] struct {
+	key        string
+	value      T
+	prev, next *bucketEntry[T]
+}
+
+// bucketStore holds per-client rate limiter state for at most maxKeys clients. Entries
+// form an intrusive LRU list, so tracking a client costs a single allocation, and the
+// least recently used client is evicted when the store is full. A single janitor
+// goroutine removes expired entries until Close is called.
+type bucketStore[T any] struct {
+	sync.Mutex
+	entries    map[string]*bucketEntry[T]
+	head, tail *bucketEntry[T]
+	maxKeys    int
+	now        func() time.Time
+	expired    func(value *T, now time.Time) bool
+	stop       chan struct{}
+	closeOnce  sync.Once
+}
+
+func newBucketStore[T any](maxKeys int, interval time.Duration, now func() time.Time, expired func(value *T, now time.Time) bool) *bucketStore[T] {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+
+	store := &bucketStore[T]{
+		entries: make(map[string]*bucketEntry[T]),
+		maxKeys: maxKeys,
+		now:     now,
+		expired: expired,
+		stop:    make(chan struct{}),
+	}
+
+	go store.janitor(max(interval, minSweepInterval))
+
+	return store
+}
+
+// get returns the state of the client, creating it with init when the client is not
+// tracked, and marks the client as most recently used. The caller must hold the lock.
+func (store *bucketStore[T]) get(key string, init func(value *T)) *T {
+	entry, exist := store.entries[key]
+	if exist {
+		store.unlink(entry)
+		store.pushFront(entry)
+		return &entry.value
+	}
+
+	if len(store.entries) >= store.maxKeys {
+		oldest := store.tail
+		store.unlink(oldest)
+		delete(store.entries, oldest.key)
+	}
+
+	entry = &bucketEntry[T]{key: key}
+	init(&entry.value)
+	store.entries[key] = entry
+	store.pushFront(entry)
+
+	return &entry.value
+}
+
+func (store *bucketStore[T]) len() int {
+	store.Lock()
+	defer store.Unlock()
+
+	return len(store.entries)
+}
+
+func (store *bucketStore[T]) sweep() {
+	store.Lock()
+	defer store.Unlock()
+
+	now := store.now()
+	for key, entry := range store.entries {
+		if store.expired(&entry.value, now) {
+			store.unlink(entry)
+			delete(store.entries, key)
+		}
+	}
+}
+
+func (store *bucketStore[T]) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-store.stop:
+			return
+		case <-ticker.C:
+			store.sweep()
+		}
+	}
+}
+
+// Close stops the janitor. It is safe to call more than once.
+func (store *bucketStore[T]) Close() error {
+	store.closeOnce.Do(func() {
+		close(store.stop)
+	})
+
+	return nil
+}
+
+func (store *bucketStore[T]) pushFront(entry *bucketEntry[T]) {
+	entry.prev = nil
+	entry.next = store.head
+	if store.head != nil {
+		store.head.prev = entry
+	}
+	store.head = entry
+	if store.tail == nil {
+		store.tail = entry
+	}
+}
+
+func (store *bucketStore[T]) unlink(entry *bucketEntry[T]) {
+	if entry.prev != nil {
+		entry.prev.next = entry.next
+	} else {
+		store.head = entry.next
+	}
+	if entry.next != nil {
+		entry.next.prev = entry.prev
+	} else {
+		store.tail = entry.prev
+	}
+	entry.prev, entry.next = nil, nil
+}
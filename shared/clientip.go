@@ -0,0 +1,196 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPContextKey struct{}
+
+// The forwarding headers a ClientIPResolver can read.
+const (
+	ForwardedHeader     = "Forwarded"
+	XForwardedForHeader = "X-Forwarded-For"
+)
+
+// ClientIPResolver finds the address of the client behind reverse proxies. Forwarding
+// headers are trusted only when the request arrives from a trusted proxy, so clients
+// can't choose the address the rate limiter counts them under.
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// NewClientIPResolver trusts proxies in the given CIDRs. Single addresses are accepted
+// as well. Without trusted proxies forwarding headers are ignored. header names the one
+// forwarding header the proxies set, Forwarded or X-Forwarded-For, which is the default.
+// The other header passes through the proxies unchanged, so it is never read.
+func NewClientIPResolver(trustedProxies []string, header string) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{header: XForwardedForHeader}
+
+	switch {
+	case header == "":
+	case strings.EqualFold(header, ForwardedHeader):
+		resolver.header = ForwardedHeader
+	case strings.EqualFold(header, XForwardedForHeader):
+		resolver.header = XForwardedForHeader
+	default:
+		return nil, fmt.Errorf("invalid forwarded header %q, expected %s or %s", header, ForwardedHeader, XForwardedForHeader)
+	}
+
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		resolver.trusted = append(resolver.trusted, prefix.Masked())
+	}
+
+	return resolver, nil
+}
+
+// ClientIP returns the address of the client. The hops of the configured forwarding
+// header are walked right to left starting from the peer, and the first address that is
+// not a trusted proxy is the client.
+func (resolver *ClientIPResolver) ClientIP(r *http.Request) string {
+	peer, ok := parseHost(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+
+	client := peer
+	if !resolver.isTrusted(client) {
+		return client.String()
+	}
+
+	var hops []string
+	if resolver.header == ForwardedHeader {
+		hops = forwardedFor(r.Header)
+	} else {
+		hops = forwardedHops(r.Header.Values(XForwardedForHeader))
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHost(hops[i])
+		if !ok {
+			// Hops left of an unparsable one can't be attributed to a trusted proxy.
+			break
+		}
+
+		client = hop
+		if !resolver.isTrusted(client) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// Middleware stores the client address in the request context and in r.RemoteAddr, so
+// rate limiting and request logging see the resolved address.
+func (resolver *ClientIPResolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := resolver.ClientIP(r)
+
+		r = r.WithContext(context.WithValue(r.Context(), clientIPContextKey{}, ip))
+		r.RemoteAddr = ip
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientIPFromContext returns the address stored by ClientIPResolver.Middleware.
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPContextKey{}).(string)
+	return ip, ok && ip != ""
+}
+
+// clientIP returns the resolved client address, or the peer address without the port
+// when the request didn't pass through ClientIPResolver.Middleware.
+func clientIP(r *http.Request) string {
+	if ip, ok := ClientIPFromContext(r.Context()); ok {
+		return ip
+	}
+	if addr, ok := parseHost(r.RemoteAddr); ok {
+		return addr.String()
+	}
+
+	return r.RemoteAddr
+}
+
+func (resolver *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range resolver.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwardedHops splits comma separated header values into hops, in order.
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	return hops
+}
+
+// forwardedFor returns the for= parameter of every Forwarded element, in order. Elements
+// without one are kept as empty hops, so they stop the walk. It returns nil when the
+// header is missing.
+func forwardedFor(header http.Header) []string {
+	values := header.Values("Forwarded")
+	if len(values) == 0 {
+		return nil
+	}
+
+	elements := forwardedHops(values)
+	hops := make([]string, 0, len(elements))
+	for _, element := range elements {
+		hop := ""
+		for _, pair := range strings.Split(element, ";") {
+			name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if found && strings.EqualFold(name, "for") {
+				hop = strings.Trim(value, `"`)
+			}
+		}
+		hops = append(hops, hop)
+	}
+
+	return hops
+}
+
+// parseHost parses an address with an optional port. IPv6 addresses with a port must
+// be enclosed in brackets. Obfuscated identifiers such as "unknown" are rejected.
+func parseHost(value string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
@@ -0,0 +1,172 @@
+package shared
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestClientIPResolver(t *testing.T, header string, trustedProxies ...string) *ClientIPResolver {
+	t.Helper()
+
+	resolver, err := NewClientIPResolver(trustedProxies, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resolver
+}
+
+func TestClientIPResolver(t *testing.T) {
+	t.Run("should ignore forwarding headers from untrusted peers", func(t *testing.T) {
+		// Arrange
+		resolver := newTestClientIPResolver(t, "", "10.0.0.0/8")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+		// Act
+		ip := resolver.ClientIP(req)
+
+		// Assert
+		assert.Equal(t, "203.0.113.7", ip)
+	})
+
+	t.Run("should walk X-Forwarded-For right to left past trusted proxies", func(t *testing.T) {
+		// Arrange
+		resolver := newTestClientIPResolver(t, "", "10.0.0.0/8", "192.0.2.10")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Add("X-Forwarded-For", "198.51.100.99, 203.0.113.7")
+		req.Header.Add("X-Forwarded-For", "192.0.2.10")
+
+		// Act
+		ip := resolver.ClientIP(req)
+
+		// Assert
+		assert.Equal(t, "203.0.113.7", ip)
+	})
+
+	t.Run("should return the leftmost hop when every hop is trusted", func(t *testing.T) {
+		// Arrange
+		resolver := newTestClientIPResolver(t, "", "10.0.0.0/8")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", "10.0.0.3, 10.0.0.2")
+
+		// Act
+		ip := resolver.ClientIP(req)
+
+		// Assert
+		assert.Equal(t, "10.0.0.3", ip)
+	})
+
+	t.Run("should stop at a hop that is not an address", func(t *testing.T) {
+		// Arrange
+		resolver := newTestClientIPResolver(t, "", "10.0.0.0/8")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", "198.51.100.1, garbage, 10.0.0.2")
+
+		// Act
+		ip := resolver.ClientIP(req)
+
+		// Assert
+		assert.Equal(t, "10.0.0.2", ip)
+	})
+
+	t.Run("should read the Forwarded header when configured", func(t *testing.T) {
+		// Arrange
+		resolver := newTestClientIPResolver(t, ForwardedHeader, "10.0.0.0/8")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Forwarded", `for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2;by=10.0.0.1`)
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+		// Act
+		ip := resolver.ClientIP(req)
+
+		// Assert
+		assert.Equal(t, "2001:db8:cafe::17", ip)
+	})
+
+	t.Run("should ignore a Forwarded header the proxy passed through", func(t *testing.T) {
+		// Arrange
+		resolver := newTestClientIPResolver(t, XForwardedForHeader, "10.0.0.0/8")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Forwarded", "for=198.51.100.1")
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+		// Act
+		ip := resolver.ClientIP(req)
+
+		// Assert
+		assert.Equal(t, "203.0.113.7", ip)
+	})
+
+	t.Run("should not fall back to X-Forwarded-For without a Forwarded header", func(t *testing.T) {
+		// Arrange
+		resolver := newTestClientIPResolver(t, ForwardedHeader, "10.0.0.0/8")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+		// Act
+		ip := resolver.ClientIP(req)
+
+		// Assert
+		assert.Equal(t, "10.0.0.1", ip)
+	})
+
+	t.Run("should stop at obfuscated Forwarded identifiers", func(t *testing.T) {
+		// Arrange
+		resolver := newTestClientIPResolver(t, ForwardedHeader, "10.0.0.0/8")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Forwarded", "for=198.51.100.1, for=unknown, for=10.0.0.2")
+
+		// Act
+		ip := resolver.ClientIP(req)
+
+		// Assert
+		assert.Equal(t, "10.0.0.2", ip)
+	})
+
+	t.Run("should reject invalid trusted proxies", func(t *testing.T) {
+		// Act
+		_, err := NewClientIPResolver([]string{"10.0.0.0/33"}, "")
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject unknown forwarding headers", func(t *testing.T) {
+		// Act
+		_, err := NewClientIPResolver(nil, "X-Real-IP")
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("should expose the client address to later handlers", func(t *testing.T) {
+		// Arrange
+		resolver := newTestClientIPResolver(t, "", "10.0.0.1")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		var remoteAddr, contextIP string
+		handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			remoteAddr = r.RemoteAddr
+			contextIP, _ = ClientIPFromContext(r.Context())
+		}))
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		// Assert
+		assert.Equal(t, "203.0.113.7", remoteAddr)
+		assert.Equal(t, "203.0.113.7", contextIP)
+	})
+}
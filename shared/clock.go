@@ -0,0 +1,14 @@
+package shared
+
+import "time"
+
+// Clock abstracts the current time so that rate limiters can be tested without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,540 @@
+package shared
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/lpernett/godotenv"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigLoader fills a struct from tagged fields:
+//
+//	Addr  string        `env:"PORT" default:":8080" required:"true"`
+//	Token string        `env:"ADMIN_TOKEN" secret:"true"`
+//	Limit shared.Config `envPrefix:"RATE_LIMIT_"`
+//
+// Values are layered, later layers overriding earlier ones: the default tag, EnvFiles,
+// ConfigFile, the environment and Args. Keys in the config file are the env names, and
+// nested tables are joined with underscores, so rate_limit.window sets RATE_LIMIT_WINDOW.
+// Flags are the env names in kebab case, e.g. -rate-limit-window. In env files and the
+// environment NAME_FILE reads the value of NAME from a file, for secrets mounted by the
+// orchestrator. Slices are comma separated.
+type ConfigLoader struct {
+	// EnvFiles are read in order. Missing files are skipped.
+	EnvFiles []string
+	// ConfigFile is a YAML or TOML file, chosen by extension. The -config flag overrides it.
+	ConfigFile string
+	Args       []string
+	// Profile is the env name of the string field choosing the profile, e.g. ENV. When
+	// set, the profile is resolved from the default, ConfigFile, the environment and
+	// Args, and the env files of the profile are read after EnvFiles. Env files can't
+	// change the profile they were chosen by.
+	Profile string
+	// LookupEnv defaults to os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
+}
+
+const (
+	ProfileDevelopment = "development"
+	ProfileTest        = "test"
+	ProfileProduction  = "production"
+)
+
+// ProfileEnvFiles returns the env files of the profile, lowest precedence first. Local
+// overrides are skipped by the test profile, so tests don't depend on a developer's setup.
+func ProfileEnvFiles(profile string) ([]string, error) {
+	switch profile {
+	case ProfileDevelopment, ProfileProduction:
+		return []string{".env", ".env." + profile, ".env.local", ".env." + profile + ".local"}, nil
+	case ProfileTest:
+		return []string{".env", ".env.test"}, nil
+	default:
+		return nil, fmt.Errorf("unknown profile %q, expected %s, %s or %s", profile, ProfileDevelopment, ProfileTest, ProfileProduction)
+	}
+}
+
+// redacted replaces the values of secret fields in RedactConfig.
+const redacted = "[REDACTED]"
+
+// RedactConfig returns the values of a config loaded by ConfigLoader keyed by env name,
+// with secrets replaced, so the effective config can be logged.
+func RedactConfig(cfg any) map[string]string {
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	fields, err := configFields(v, "")
+	if err != nil {
+		return nil
+	}
+
+	values := make(map[string]string, len(fields))
+	for _, field := range fields {
+		value := formatConfigValue(field.value)
+		if field.secret && value != "" {
+			value = redacted
+		}
+		values[field.env] = value
+	}
+
+	return values
+}
+
+// Validator is implemented by configs checking rules that span several fields.
+type Validator interface {
+	Validate() error
+}
+
+type configField struct {
+	env      string
+	flag     string
+	def      string
+	hasDef   bool
+	required bool
+	secret   bool
+	value    reflect.Value
+}
+
+type configSource struct {
+	name   string
+	lookup func(key string) (string, bool)
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Load fills dst, a pointer to a struct, and reports every invalid or missing value at once.
+func (loader ConfigLoader) Load(dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", dst)
+	}
+
+	fields, err := configFields(v.Elem(), "")
+	if err != nil {
+		return err
+	}
+
+	flags, configFile, err := loader.parseFlags(fields)
+	if err != nil {
+		return err
+	}
+
+	envFiles := loader.EnvFiles
+	profileField, profile, err := loader.profile(fields, configFile, flags)
+	if err != nil {
+		return err
+	}
+	if profileField != nil {
+		profileFiles, err := ProfileEnvFiles(profile)
+		if err != nil {
+			return fmt.Errorf("%s: %w", loader.Profile, err)
+		}
+		envFiles = append(slices.Clone(envFiles), profileFiles...)
+	}
+
+	sources, err := loader.sources(envFiles, configFile)
+	if err != nil {
+		return err
+	}
+	sources = append(sources, configSource{name: "flags", lookup: mapLookup(flags)})
+
+	var errs []error
+	for _, field := range fields {
+		if err := field.load(sources); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if profileField != nil && profileField.value.String() != profile {
+		errs = append(errs, fmt.Errorf("%s: the profile %q can't be changed to %q by an env file", loader.Profile, profile, profileField.value.String()))
+	}
+
+	if len(errs) == 0 {
+		if validator, ok := dst.(Validator); ok {
+			errs = append(errs, validator.Validate())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// profile resolves the field named by Profile from every layer but the env files. It
+// returns a nil field when Profile is empty.
+func (loader ConfigLoader) profile(fields []configField, configFile string, flags map[string]string) (*configField, string, error) {
+	if loader.Profile == "" {
+		return nil, "", nil
+	}
+
+	index := slices.IndexFunc(fields, func(field configField) bool {
+		return field.env == loader.Profile
+	})
+	if index < 0 || fields[index].value.Kind() != reflect.String {
+		return nil, "", fmt.Errorf("profile %s is not a string field of the config", loader.Profile)
+	}
+	field := &fields[index]
+
+	sources, err := loader.sources(nil, configFile)
+	if err != nil {
+		return nil, "", err
+	}
+	sources = append(sources, configSource{name: "flags", lookup: mapLookup(flags)})
+
+	if err := field.load(sources); err != nil {
+		return nil, "", err
+	}
+
+	return field, field.value.String(), nil
+}
+
+func (loader ConfigLoader) sources(envFiles []string, configFile string) ([]configSource, error) {
+	var sources []configSource
+
+	for _, path := range envFiles {
+		values, err := godotenv.Read(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		sources = append(sources, configSource{name: path, lookup: mapLookup(values)})
+	}
+
+	if configFile != "" {
+		values, err := readConfigFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, configSource{name: configFile, lookup: mapLookup(values)})
+	}
+
+	lookupEnv := loader.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	sources = append(sources, configSource{name: "environment", lookup: lookupEnv})
+
+	return sources, nil
+}
+
+// parseFlags returns the flags that were set, keyed by env name, and the config file.
+func (loader ConfigLoader) parseFlags(fields []configField) (map[string]string, string, error) {
+	set := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := set.String("config", loader.ConfigFile, "YAML or TOML config file")
+
+	envNames := make(map[string]string, len(fields))
+	for _, field := range fields {
+		usage := "sets " + field.env
+		if field.hasDef && !field.secret {
+			usage += " (default " + strconv.Quote(field.def) + ")"
+		}
+		set.Var(&configFlag{isBool: field.value.Kind() == reflect.Bool}, field.flag, usage)
+		envNames[field.flag] = field.env
+	}
+
+	if err := set.Parse(loader.Args); err != nil {
+		return nil, "", err
+	}
+
+	values := make(map[string]string)
+	set.Visit(func(f *flag.Flag) {
+		if env, ok := envNames[f.Name]; ok {
+			values[env] = f.Value.String()
+		}
+	})
+
+	return values, *configFile, nil
+}
+
+// load applies the sources in order and stores the value of the last one setting the field.
+func (field configField) load(sources []configSource) error {
+	raw, from, found := field.def, "default", field.hasDef
+
+	for _, source := range sources {
+		value, ok := source.lookup(field.env)
+		path, fromFile := source.lookup(field.env + "_FILE")
+		if ok && fromFile {
+			return fmt.Errorf("%s: both %s and %s_FILE are set in %s", field.env, field.env, field.env, source.name)
+		}
+
+		if fromFile {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("%s_FILE from %s: %w", field.env, source.name, err)
+			}
+			value, ok = strings.TrimRight(string(content), "\r\n"), true
+		}
+
+		if ok {
+			raw, from, found = value, source.name, true
+		}
+	}
+
+	if !found || (field.required && raw == "") {
+		if field.required {
+			return fmt.Errorf("%s is required", field.env)
+		}
+		return nil
+	}
+
+	if err := setConfigValue(field.value, raw); err != nil {
+		if field.secret {
+			return fmt.Errorf("%s from %s: invalid value", field.env, from)
+		}
+		return fmt.Errorf("%s from %s: invalid value %q: %w", field.env, from, raw, err)
+	}
+
+	return nil
+}
+
+func configFields(v reflect.Value, prefix string) ([]configField, error) {
+	var fields []configField
+
+	for i := 0; i < v.NumField(); i++ {
+		structField := v.Type().Field(i)
+		value := v.Field(i)
+
+		env, tagged := structField.Tag.Lookup("env")
+		if !tagged {
+			if value.Kind() == reflect.Struct && structField.IsExported() && !isConfigValue(value.Type()) {
+				nested, err := configFields(value, prefix+structField.Tag.Get("envPrefix"))
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, nested...)
+			}
+			continue
+		}
+
+		if !structField.IsExported() {
+			return nil, fmt.Errorf("config field %s must be exported", structField.Name)
+		}
+
+		field := configField{
+			env:      prefix + env,
+			required: structField.Tag.Get("required") == "true",
+			secret:   structField.Tag.Get("secret") == "true",
+			value:    value,
+		}
+		field.def, field.hasDef = structField.Tag.Lookup("default")
+		field.flag = structField.Tag.Get("flag")
+		if field.flag == "" {
+			field.flag = strings.ToLower(strings.ReplaceAll(field.env, "_", "-"))
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// isConfigValue reports whether a struct is a single value rather than a group of fields.
+func isConfigValue(t reflect.Type) bool {
+	return t == urlType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func setConfigValue(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setConfigValue(v.Elem(), raw)
+	}
+
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch v.Type() {
+	case durationType:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(duration))
+		return nil
+	case urlType:
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return errors.New("url needs a scheme and a host")
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setConfigValue(slice.Index(i), item); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func formatConfigValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatConfigValue(v.Index(i))
+		}
+		return strings.Join(items, ",")
+	}
+
+	if v.CanAddr() {
+		if stringer, ok := v.Addr().Interface().(fmt.Stringer); ok {
+			return stringer.String()
+		}
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+// readConfigFile decodes a YAML or TOML file into values keyed by env name.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("%s: config file must be YAML or TOML", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flattenConfig(values, "", document)
+
+	return values, nil
+}
+
+func flattenConfig(values map[string]string, prefix string, node any) {
+	switch node := node.(type) {
+	case map[string]any:
+		for key, child := range node {
+			name := strings.ToUpper(key)
+			if prefix != "" {
+				name = prefix + "_" + name
+			}
+			flattenConfig(values, name, child)
+		}
+	case []any:
+		items := make([]string, len(node))
+		for i, item := range node {
+			items[i] = configScalar(item)
+		}
+		values[prefix] = strings.Join(items, ",")
+	default:
+		values[prefix] = configScalar(node)
+	}
+}
+
+func configScalar(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func mapLookup(values map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+// configFlag records the raw flag value, as fields are parsed once all layers are known.
+type configFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *configFlag) String() string {
+	return f.value
+}
+
+func (f *configFlag) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+func (f *configFlag) IsBoolFlag() bool {
+	return f.isBool
+}
@@ -0,0 +1,324 @@
+package shared
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testAppConfig struct {
+	Addr        string        `env:"PORT" default:":8080"`
+	Token       string        `env:"TOKEN" secret:"true"`
+	Interval    time.Duration `env:"INTERVAL" default:"1m"`
+	Origins     []string      `env:"ORIGINS"`
+	Ports       []int         `env:"PORTS"`
+	Upstream    *url.URL      `env:"UPSTREAM"`
+	Name        string        `env:"NAME" required:"true"`
+	RateLimiter Config        `envPrefix:"RATE_LIMIT_"`
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func testEnv(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestConfigLoader(t *testing.T) {
+	t.Run("should apply defaults and parse typed values", func(t *testing.T) {
+		// Arrange
+		loader := ConfigLoader{LookupEnv: testEnv(map[string]string{
+			"NAME":              "products",
+			"ORIGINS":           "https://a.example, https://b.example",
+			"PORTS":             "80,443",
+			"UPSTREAM":          "https://upstream.example:8443/api",
+			"RATE_LIMIT_WINDOW": "30s",
+		})}
+		var config testAppConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, ":8080", config.Addr)
+		assert.Equal(t, time.Minute, config.Interval)
+		assert.Equal(t, []string{"https://a.example", "https://b.example"}, config.Origins)
+		assert.Equal(t, []int{80, 443}, config.Ports)
+		assert.Equal(t, "upstream.example:8443", config.Upstream.Host)
+		assert.Equal(t, 30*time.Second, config.RateLimiter.TimeFrame)
+		assert.Equal(t, 100, config.RateLimiter.RequestPerTimeFrame)
+		assert.Equal(t, FixedWindow, config.RateLimiter.Algorithm)
+	})
+
+	t.Run("should layer env files, config file, environment and flags", func(t *testing.T) {
+		// Arrange
+		envFile := writeTestFile(t, ".env", "NAME=from-env-file\nPORT=:1000\nINTERVAL=2m\nRATE_LIMIT_MAX_REQUESTS=5\n")
+		configFile := writeTestFile(t, "config.yaml", "port: \":2000\"\ninterval: 3m\nrate_limit:\n  max_requests: 50\n  enabled: true\n")
+		loader := ConfigLoader{
+			EnvFiles:   []string{envFile, filepath.Join(t.TempDir(), "missing.env")},
+			ConfigFile: configFile,
+			Args:       []string{"-port", ":3000", "-rate-limit-enabled=false"},
+			LookupEnv:  testEnv(map[string]string{"INTERVAL": "4m"}),
+		}
+		var config testAppConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "from-env-file", config.Name)
+		assert.Equal(t, ":3000", config.Addr)
+		assert.Equal(t, 4*time.Minute, config.Interval)
+		assert.Equal(t, 50, config.RateLimiter.RequestPerTimeFrame)
+		assert.False(t, config.RateLimiter.Enabled)
+	})
+
+	t.Run("should read TOML config files", func(t *testing.T) {
+		// Arrange
+		configFile := writeTestFile(t, "config.toml", "name = \"products\"\nports = [80, 443]\n\n[rate_limit]\nwindow = \"10s\"\n")
+		loader := ConfigLoader{Args: []string{"-config", configFile}, LookupEnv: testEnv(nil)}
+		var config testAppConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "products", config.Name)
+		assert.Equal(t, []int{80, 443}, config.Ports)
+		assert.Equal(t, 10*time.Second, config.RateLimiter.TimeFrame)
+	})
+
+	t.Run("should read secrets from files", func(t *testing.T) {
+		// Arrange
+		secret := writeTestFile(t, "token", "s3cret\n")
+		loader := ConfigLoader{LookupEnv: testEnv(map[string]string{"NAME": "products", "TOKEN_FILE": secret})}
+		var config testAppConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cret", config.Token)
+	})
+
+	t.Run("should report every invalid value together", func(t *testing.T) {
+		// Arrange
+		loader := ConfigLoader{LookupEnv: testEnv(map[string]string{
+			"INTERVAL":                "1minute",
+			"PORTS":                   "80,https",
+			"UPSTREAM":                "not a url",
+			"RATE_LIMIT_MAX_REQUESTS": "many",
+		})}
+		var config testAppConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.ErrorContains(t, err, `INTERVAL from environment: invalid value "1minute"`)
+		assert.ErrorContains(t, err, "PORTS from environment")
+		assert.ErrorContains(t, err, "UPSTREAM from environment")
+		assert.ErrorContains(t, err, "RATE_LIMIT_MAX_REQUESTS from environment")
+		assert.ErrorContains(t, err, "NAME is required")
+	})
+
+	t.Run("should not reveal invalid secrets", func(t *testing.T) {
+		// Arrange
+		loader := ConfigLoader{LookupEnv: testEnv(map[string]string{"PIN": "s3cret"})}
+		type secretConfig struct {
+			Pin int `env:"PIN" secret:"true"`
+		}
+		var config secretConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.ErrorContains(t, err, "PIN from environment: invalid value")
+		assert.NotContains(t, err.Error(), "s3cret")
+	})
+
+	t.Run("should reject a value set both directly and from a file", func(t *testing.T) {
+		// Arrange
+		loader := ConfigLoader{LookupEnv: testEnv(map[string]string{"NAME": "products", "TOKEN": "a", "TOKEN_FILE": "b"})}
+		var config testAppConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.ErrorContains(t, err, "both TOKEN and TOKEN_FILE are set")
+	})
+
+	t.Run("should reject unknown flags", func(t *testing.T) {
+		// Arrange
+		loader := ConfigLoader{Args: []string{"-unknown"}, LookupEnv: testEnv(nil)}
+		var config testAppConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+type testProfileConfig struct {
+	Env  string `env:"ENV" default:"development"`
+	Name string `env:"NAME"`
+}
+
+// chdirTemp runs the test in a new directory holding the env files, as the profile env
+// files are relative to the working directory.
+func chdirTemp(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+}
+
+func TestConfigLoaderProfile(t *testing.T) {
+	t.Run("should read the env files of the profile chosen by a flag", func(t *testing.T) {
+		// Arrange
+		chdirTemp(t, map[string]string{".env": "NAME=base\n", ".env.test": "NAME=test\n", ".env.development": "NAME=development\n"})
+		loader := ConfigLoader{Profile: "ENV", Args: []string{"-env", ProfileTest}, LookupEnv: testEnv(nil)}
+		var config testProfileConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, ProfileTest, config.Env)
+		assert.Equal(t, "test", config.Name)
+	})
+
+	t.Run("should read the profile from the config file", func(t *testing.T) {
+		// Arrange
+		chdirTemp(t, map[string]string{".env.production": "NAME=production\n", "config.yaml": "env: production\n"})
+		loader := ConfigLoader{Profile: "ENV", ConfigFile: "config.yaml", LookupEnv: testEnv(nil)}
+		var config testProfileConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "production", config.Name)
+	})
+
+	t.Run("should reject env files changing the profile", func(t *testing.T) {
+		// Arrange
+		chdirTemp(t, map[string]string{".env": "ENV=production\n"})
+		loader := ConfigLoader{Profile: "ENV", LookupEnv: testEnv(nil)}
+		var config testProfileConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject unknown profiles", func(t *testing.T) {
+		// Arrange
+		loader := ConfigLoader{Profile: "ENV", LookupEnv: testEnv(map[string]string{"ENV": "staging"})}
+		var config testProfileConfig
+
+		// Act
+		err := loader.Load(&config)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestProfileEnvFiles(t *testing.T) {
+	t.Run("should load local overrides after the profile files", func(t *testing.T) {
+		// Act
+		files, err := ProfileEnvFiles(ProfileProduction)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{".env", ".env.production", ".env.local", ".env.production.local"}, files)
+	})
+
+	t.Run("should skip local overrides in the test profile", func(t *testing.T) {
+		// Act
+		files, err := ProfileEnvFiles(ProfileTest)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{".env", ".env.test"}, files)
+	})
+
+	t.Run("should reject unknown profiles", func(t *testing.T) {
+		// Act
+		_, err := ProfileEnvFiles("staging")
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestRedactConfig(t *testing.T) {
+	t.Run("should replace secrets and format values", func(t *testing.T) {
+		// Arrange
+		upstream, _ := url.Parse("https://upstream.example")
+		config := testAppConfig{
+			Addr:     ":8080",
+			Token:    "s3cret",
+			Interval: time.Minute,
+			Ports:    []int{80, 443},
+			Upstream: upstream,
+			RateLimiter: Config{
+				RedisURL: "redis://:s3cret@localhost:6379",
+			},
+		}
+
+		// Act
+		values := RedactConfig(&config)
+
+		// Assert
+		assert.Equal(t, ":8080", values["PORT"])
+		assert.Equal(t, "[REDACTED]", values["TOKEN"])
+		assert.Equal(t, "1m0s", values["INTERVAL"])
+		assert.Equal(t, "80,443", values["PORTS"])
+		assert.Equal(t, "https://upstream.example", values["UPSTREAM"])
+		assert.Equal(t, "[REDACTED]", values["RATE_LIMIT_REDIS_URL"])
+		assert.Equal(t, "", values["ORIGINS"])
+	})
+}
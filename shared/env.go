@@ -6,6 +6,7 @@ import (
 	"time"
 )
 
+// Deprecated: use ConfigLoader, which reports malformed values instead of using the default.
 func GetString(key, defaultValue string) string {
 	val, ok := os.LookupEnv(key)
 	if !ok {
@@ -14,6 +15,7 @@ func GetString(key, defaultValue string) string {
 	return val
 }
 
+// Deprecated: use ConfigLoader, which reports malformed values instead of using the default.
 func GetInt(key string, defaultValue int) int {
 	val, ok := os.LookupEnv(key)
 	if !ok {
@@ -28,6 +30,7 @@ func GetInt(key string, defaultValue int) int {
 	return intVal
 }
 
+// Deprecated: use ConfigLoader, which reports malformed values instead of using the default.
 func GetBool(key string, defaultValue bool) bool {
 	val, ok := os.LookupEnv(key)
 	if !ok {
@@ -42,6 +45,7 @@ func GetBool(key string, defaultValue bool) bool {
 	return boolVal
 }
 
+// Deprecated: use ConfigLoader, which reports malformed values instead of using the default.
 func GetDuration(key string, defaultValue time.Duration) time.Duration {
 	val, ok := os.LookupEnv(key)
 	if !ok {
@@ -0,0 +1,190 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HealthStatusPass = "pass"
+	HealthStatusFail = "fail"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// HealthChecker is implemented by the backends the application needs to serve requests,
+// e.g. storage, caches and message buses.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheckerFunc adapts a function to HealthChecker.
+type HealthCheckerFunc func(ctx context.Context) error
+
+func (f HealthCheckerFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Health answers the liveness and readiness probes. Liveness only reports that the
+// process serves requests, readiness runs the registered checks.
+type Health struct {
+	mu       sync.RWMutex
+	checkers map[string]HealthChecker
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// NewHealth bounds every check by timeout.
+func NewHealth(timeout time.Duration) *Health {
+	return &Health{
+		checkers: make(map[string]HealthChecker),
+		timeout:  timeout,
+	}
+}
+
+// Register adds a readiness check, replacing the check registered under the same name.
+func (health *Health) Register(name string, checker HealthChecker) {
+	health.mu.Lock()
+	defer health.mu.Unlock()
+
+	health.checkers[name] = checker
+}
+
+// Drain fails readiness from now on, so load balancers stop sending requests before
+// the server shuts down.
+func (health *Health) Drain() {
+	health.draining.Store(true)
+}
+
+// Live reports that the process is serving requests.
+func (health *Health) Live() HealthReport {
+	return HealthReport{Status: HealthStatusPass}
+}
+
+// Ready runs the registered checks concurrently. It fails when a check fails or the
+// server is draining. It returns at the timeout even when a check ignores its context,
+// reporting the checks still running as failed.
+func (health *Health) Ready(ctx context.Context) HealthReport {
+	if health.draining.Load() {
+		return HealthReport{
+			Status: HealthStatusFail,
+			Checks: map[string]CheckResult{"shutdown": {Status: HealthStatusFail, Error: errShuttingDown.Error()}},
+		}
+	}
+
+	health.mu.RLock()
+	checkers := make(map[string]HealthChecker, len(health.checkers))
+	for name, checker := range health.checkers {
+		checkers[name] = checker
+	}
+	health.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, health.timeout)
+	defer cancel()
+
+	type namedResult struct {
+		name   string
+		result CheckResult
+	}
+
+	start := time.Now()
+	results := make(chan namedResult, len(checkers))
+	for name, checker := range checkers {
+		go func() {
+			results <- namedResult{name: name, result: check(ctx, checker)}
+		}()
+	}
+
+	report := HealthReport{Status: HealthStatusPass, Checks: make(map[string]CheckResult, len(checkers))}
+	record := func(finished namedResult) {
+		report.Checks[finished.name] = finished.result
+		if finished.result.Status == HealthStatusFail {
+			report.Status = HealthStatusFail
+		}
+	}
+	for range checkers {
+		select {
+		case finished := <-results:
+			record(finished)
+		case <-ctx.Done():
+			// Keep the results that arrived along with the timeout.
+			for len(results) > 0 {
+				record(<-results)
+			}
+			report.Status = HealthStatusFail
+			for name := range checkers {
+				if _, done := report.Checks[name]; !done {
+					report.Checks[name] = CheckResult{
+						Status:    HealthStatusFail,
+						LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+						Error:     ctx.Err().Error(),
+					}
+				}
+			}
+			return report
+		}
+	}
+
+	return report
+}
+
+func check(ctx context.Context, checker HealthChecker) CheckResult {
+	start := time.Now()
+	err := checker.CheckHealth(ctx)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:    HealthStatusPass,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = HealthStatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// LivenessHandler serves the liveness probe.
+func (health *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, health.Live())
+	})
+}
+
+// ReadinessHandler serves the readiness probe, answering 503 while it fails.
+func (health *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, health.Ready(r.Context()))
+	})
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	status := http.StatusOK
+	if report.Status != HealthStatusPass {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,97 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	t.Run("should pass when every check passes", func(t *testing.T) {
+		// Arrange
+		health := NewHealth(time.Second)
+		health.Register("storage", HealthCheckerFunc(func(ctx context.Context) error { return nil }))
+
+		// Act
+		report := health.Ready(context.Background())
+
+		// Assert
+		assert.Equal(t, HealthStatusPass, report.Status)
+		assert.Equal(t, HealthStatusPass, report.Checks["storage"].Status)
+	})
+
+	t.Run("should fail when a check fails", func(t *testing.T) {
+		// Arrange
+		health := NewHealth(time.Second)
+		health.Register("storage", HealthCheckerFunc(func(ctx context.Context) error { return nil }))
+		health.Register("cache", HealthCheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") }))
+
+		// Act
+		report := health.Ready(context.Background())
+
+		// Assert
+		assert.Equal(t, HealthStatusFail, report.Status)
+		assert.Equal(t, HealthStatusPass, report.Checks["storage"].Status)
+		assert.Equal(t, CheckResult{Status: HealthStatusFail, LatencyMS: report.Checks["cache"].LatencyMS, Error: "connection refused"}, report.Checks["cache"])
+	})
+
+	t.Run("should fail checks running past the timeout", func(t *testing.T) {
+		// Arrange
+		health := NewHealth(10 * time.Millisecond)
+		health.Register("bus", HealthCheckerFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+
+		// Act
+		report := health.Ready(context.Background())
+
+		// Assert
+		assert.Equal(t, HealthStatusFail, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["bus"].Error)
+	})
+
+	t.Run("should return at the timeout when a check ignores its context", func(t *testing.T) {
+		// Arrange
+		health := NewHealth(10 * time.Millisecond)
+		release := make(chan struct{})
+		t.Cleanup(func() { close(release) })
+		health.Register("storage", HealthCheckerFunc(func(ctx context.Context) error { return nil }))
+		health.Register("bus", HealthCheckerFunc(func(ctx context.Context) error {
+			<-release
+			return nil
+		}))
+
+		// Act
+		report := health.Ready(context.Background())
+
+		// Assert
+		assert.Equal(t, HealthStatusFail, report.Status)
+		assert.Equal(t, HealthStatusPass, report.Checks["storage"].Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["bus"].Error)
+	})
+
+	t.Run("should fail readiness but not liveness while draining", func(t *testing.T) {
+		// Arrange
+		health := NewHealth(time.Second)
+		health.Drain()
+
+		// Act
+		ready := httptest.NewRecorder()
+		health.ReadinessHandler().ServeHTTP(ready, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		live := httptest.NewRecorder()
+		health.LivenessHandler().ServeHTTP(live, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusServiceUnavailable, ready.Code)
+		assert.Equal(t, http.StatusOK, live.Code)
+		var report HealthReport
+		assert.NoError(t, json.NewDecoder(ready.Body).Decode(&report))
+		assert.Equal(t, HealthStatusFail, report.Checks["shutdown"].Status)
+	})
+}
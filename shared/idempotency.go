@@ -0,0 +1,317 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentBodyBytes    = 1 << 20
+	idempotencyStoreTimeout   = 100 * time.Millisecond
+	idempotencySweepInterval  = time.Minute
+	redisIdempotencyKeyPrefix = "idempotency:"
+)
+
+type IdempotencyConfig struct {
+	// TTL is how long a completed response is replayed for its key.
+	TTL time.Duration `env:"TTL" default:"24h"`
+	// LockTimeout is how long a key stays in progress when its request never completes,
+	// e.g. because the replica serving it crashed.
+	LockTimeout time.Duration `env:"LOCK_TIMEOUT" default:"1m"`
+	// RedisURL, when set, shares the keys between replicas through Redis.
+	RedisURL string `env:"REDIS_URL" secret:"true"`
+}
+
+// IdempotencyRecord is the state of an idempotency key: in progress until Completed, then
+// holding the response replayed to retries.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request that first used the key.
+	Fingerprint string      `json:"fingerprint"`
+	Completed   bool        `json:"completed"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+type IdempotencyStore interface {
+	// Begin stores record for key unless the key is taken, in which case it returns the
+	// stored record and false. The record expires after ttl.
+	Begin(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool, error)
+	// Complete replaces the record of key, keeping it for ttl.
+	Complete(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error
+	// Release frees key, so that the request can be retried.
+	Release(ctx context.Context, key string) error
+	Close() error
+}
+
+// NewIdempotencyStore creates an in-memory store, or a Redis store when config.RedisURL is set.
+func NewIdempotencyStore(config IdempotencyConfig) (IdempotencyStore, error) {
+	if config.RedisURL == "" {
+		return NewMemoryIdempotencyStore(), nil
+	}
+
+	store, err := NewRedisIdempotencyStoreFromURL(config.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+type memoryIdempotencyEntry struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+// MemoryIdempotencyStore keeps the keys of a single replica. Expired keys are swept while
+// new keys are stored.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryIdempotencyEntry
+	clock     Clock
+	lastSweep time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]memoryIdempotencyEntry),
+		clock:   systemClock{},
+	}
+}
+
+func (store *MemoryIdempotencyStore) Begin(_ context.Context, key string, record IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.clock.Now()
+	if now.Sub(store.lastSweep) >= idempotencySweepInterval {
+		for key, entry := range store.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(store.entries, key)
+			}
+		}
+		store.lastSweep = now
+	}
+
+	if entry, ok := store.entries[key]; ok && now.Before(entry.expiresAt) {
+		return entry.record, false, nil
+	}
+
+	store.entries[key] = memoryIdempotencyEntry{record: record, expiresAt: now.Add(ttl)}
+
+	return record, true, nil
+}
+
+func (store *MemoryIdempotencyStore) Complete(_ context.Context, key string, record IdempotencyRecord, ttl time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.entries[key] = memoryIdempotencyEntry{record: record, expiresAt: store.clock.Now().Add(ttl)}
+
+	return nil
+}
+
+func (store *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.entries, key)
+
+	return nil
+}
+
+func (store *MemoryIdempotencyStore) Close() error {
+	return nil
+}
+
+// Idempotency stores the first response to a mutating request carrying an Idempotency-Key
+// header and replays it to retries of the request, so that a retried POST doesn't create a
+// second resource. Keys are scoped by the user, the API key or the client IP. A key
+// reused for a different request is rejected with 422 and a retry arriving while the
+// first request is in progress with 409. Responses with a 5xx status aren't stored, so
+// that the request can be retried. Store failures are logged and the request is served
+// without idempotency.
+type Idempotency struct {
+	store       IdempotencyStore
+	ttl         time.Duration
+	lockTimeout time.Duration
+	logger      *zap.SugaredLogger
+}
+
+func NewIdempotency(config IdempotencyConfig, store IdempotencyStore, logger *zap.SugaredLogger) *Idempotency {
+	return &Idempotency{
+		store:       store,
+		ttl:         config.TTL,
+		lockTimeout: config.LockTimeout,
+		logger:      logger,
+	}
+}
+
+func (idempotency *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger := LoggerFromContext(r.Context(), idempotency.logger)
+
+		if len(key) > maxIdempotencyKeyLength {
+			_ = WriteProblem(w, r, NewProblem(http.StatusBadRequest, "the Idempotency-Key header must not be longer than 255 characters"))
+			return
+		}
+
+		fingerprint, err := requestFingerprint(r)
+		if err != nil {
+			// The body is too large to be replayed, the handler will reject it.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key = idempotencyScope(r) + ":" + key
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), idempotencyStoreTimeout)
+		stored, ok, err := idempotency.store.Begin(ctx, key, IdempotencyRecord{Fingerprint: fingerprint}, idempotency.lockTimeout)
+		cancel()
+		if err != nil {
+			logger.Warnw("idempotency store unavailable, serving without idempotency", "error", err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !ok {
+			switch {
+			case stored.Fingerprint != fingerprint:
+				logger.Warnw("idempotency key reused for a different request", "method", r.Method, "path", r.URL.Path)
+				_ = WriteProblem(w, r, NewProblem(http.StatusUnprocessableEntity, "the Idempotency-Key was already used for a different request"))
+			case !stored.Completed:
+				_ = WriteProblem(w, r, NewProblem(http.StatusConflict, "a request with the same Idempotency-Key is in progress, retry later"))
+			default:
+				replay(w, stored)
+			}
+			return
+		}
+
+		idempotency.serve(w, r, next, key, fingerprint, logger)
+	})
+}
+
+// serve calls next, recording its response under key, or releases key when the response
+// isn't stored.
+func (idempotency *Idempotency) serve(w http.ResponseWriter, r *http.Request, next http.Handler, key, fingerprint string, logger *zap.SugaredLogger) {
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), idempotencyStoreTimeout)
+		defer cancel()
+		if err := idempotency.store.Release(ctx, key); err != nil {
+			logger.Errorw("releasing idempotency key failed", "error", err.Error())
+		}
+	}()
+
+	// Headers set before the handler runs, e.g. by the rate limiter, describe this
+	// request only and aren't replayed.
+	preset := make(map[string]bool, len(w.Header()))
+	for name := range w.Header() {
+		preset[name] = true
+	}
+
+	var body bytes.Buffer
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	ww.Tee(&body)
+	next.ServeHTTP(ww, r)
+
+	status := ww.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status >= http.StatusInternalServerError {
+		return
+	}
+
+	header := make(http.Header)
+	for name, values := range w.Header() {
+		if !preset[name] {
+			header[name] = values
+		}
+	}
+
+	record := IdempotencyRecord{Fingerprint: fingerprint, Completed: true, Status: status, Header: header, Body: body.Bytes()}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), idempotencyStoreTimeout)
+	defer cancel()
+	if err := idempotency.store.Complete(ctx, key, record, idempotency.ttl); err != nil {
+		logger.Errorw("storing idempotent response failed", "error", err.Error())
+		return
+	}
+	completed = true
+}
+
+func (idempotency *Idempotency) Close() error {
+	return idempotency.store.Close()
+}
+
+func replay(w http.ResponseWriter, record IdempotencyRecord) {
+	for name, values := range record.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	_, _ = w.Write(record.Body)
+}
+
+func isMutating(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete
+}
+
+// requestFingerprint hashes the method, path and body of r, restoring the body for the handler.
+func requestFingerprint(r *http.Request) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodyBytes+1))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if err != nil {
+			return "", err
+		}
+		if len(body) > maxIdempotentBodyBytes {
+			return "", errors.New("request body too large")
+		}
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// idempotencyScope keeps the keys of different clients apart. Only identities stored by
+// authentication are used, never the raw headers of the request.
+func idempotencyScope(r *http.Request) string {
+	for _, identity := range []Identity{IdentityUser, IdentityAPIKey} {
+		if value, ok := resolveIdentity(r, identity); ok {
+			return string(identity) + ":" + value
+		}
+	}
+
+	return string(IdentityIP) + ":" + clientIP(r)
+}
@@ -0,0 +1,266 @@
+package shared
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testIdempotencyConfig = IdempotencyConfig{TTL: time.Hour, LockTimeout: time.Minute}
+
+// newTestIdempotentHandler counts the calls reaching the handler, which answers 201 with
+// the request body. The handler blocks until release is closed, when it isn't nil.
+func newTestIdempotentHandler(store IdempotencyStore, calls *atomic.Int64, release chan struct{}) http.Handler {
+	idempotency := NewIdempotency(testIdempotencyConfig, store, zap.NewNop().Sugar())
+
+	return idempotency.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if release != nil {
+			<-release
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Location", "/products/1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	}))
+}
+
+func newIdempotentRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	return req
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Run("should replay the stored response to a retry", func(t *testing.T) {
+		// Arrange
+		var calls atomic.Int64
+		handler := newTestIdempotentHandler(NewMemoryIdempotencyStore(), &calls, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest("key", `{"name":"a"}`))
+		rr := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(rr, newIdempotentRequest("key", `{"name":"a"}`))
+
+		// Assert
+		assert.Equal(t, int64(1), calls.Load())
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, `{"name":"a"}`, rr.Body.String())
+		assert.Equal(t, "/products/1", rr.Header().Get("Location"))
+		assert.Equal(t, "true", rr.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("should reject a key reused for a different request", func(t *testing.T) {
+		// Arrange
+		var calls atomic.Int64
+		handler := newTestIdempotentHandler(NewMemoryIdempotencyStore(), &calls, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest("key", `{"name":"a"}`))
+		rr := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(rr, newIdempotentRequest("key", `{"name":"b"}`))
+
+		// Assert
+		assert.Equal(t, int64(1), calls.Load())
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	})
+
+	t.Run("should reject a retry while the request is in progress", func(t *testing.T) {
+		// Arrange
+		var calls atomic.Int64
+		release := make(chan struct{})
+		handler := newTestIdempotentHandler(NewMemoryIdempotencyStore(), &calls, release)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			handler.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest("key", `{"name":"a"}`))
+		}()
+		for calls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		rr := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(rr, newIdempotentRequest("key", `{"name":"a"}`))
+		close(release)
+		<-done
+
+		// Assert
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, int64(1), calls.Load())
+	})
+
+	t.Run("should scope keys by client", func(t *testing.T) {
+		// Arrange
+		var calls atomic.Int64
+		handler := newTestIdempotentHandler(NewMemoryIdempotencyStore(), &calls, nil)
+		first := newIdempotentRequest("key", `{"name":"a"}`)
+		first.RemoteAddr = "192.0.2.1:1234"
+		second := newIdempotentRequest("key", `{"name":"a"}`)
+		second.RemoteAddr = "192.0.2.2:1234"
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), first)
+		handler.ServeHTTP(httptest.NewRecorder(), second)
+
+		// Assert
+		assert.Equal(t, int64(2), calls.Load())
+	})
+
+	t.Run("should scope keys by validated API keys only", func(t *testing.T) {
+		// Arrange
+		var calls atomic.Int64
+		handler := newTestIdempotentHandler(NewMemoryIdempotencyStore(), &calls, nil)
+		first := newIdempotentRequest("key", `{"name":"a"}`)
+		first.Header.Set(APIKeyHeader, "made-up")
+		second := newIdempotentRequest("key", `{"name":"a"}`)
+		second.Header.Set(APIKeyHeader, "another")
+		partner := newIdempotentRequest("key", `{"name":"a"}`)
+		partner = partner.WithContext(WithAPIKey(partner.Context(), "secret"))
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), first)
+		handler.ServeHTTP(httptest.NewRecorder(), second)
+		handler.ServeHTTP(httptest.NewRecorder(), partner)
+
+		// Assert
+		assert.Equal(t, int64(2), calls.Load())
+	})
+
+	t.Run("should let a failed request be retried", func(t *testing.T) {
+		// Arrange
+		var calls atomic.Int64
+		idempotency := NewIdempotency(testIdempotencyConfig, NewMemoryIdempotencyStore(), zap.NewNop().Sugar())
+		handler := idempotency.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest("key", "{}"))
+		rr := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(rr, newIdempotentRequest("key", "{}"))
+
+		// Assert
+		assert.Equal(t, int64(2), calls.Load())
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	})
+
+	t.Run("should reject keys longer than 255 characters", func(t *testing.T) {
+		// Arrange
+		var calls atomic.Int64
+		handler := newTestIdempotentHandler(NewMemoryIdempotencyStore(), &calls, nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(rr, newIdempotentRequest(strings.Repeat("k", 256), "{}"))
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, int64(0), calls.Load())
+	})
+
+	t.Run("should serve requests when the store is down", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		store := NewRedisIdempotencyStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+		store.ownsClient = true
+		t.Cleanup(func() {
+			_ = store.Close()
+		})
+		var calls atomic.Int64
+		handler := newTestIdempotentHandler(store, &calls, nil)
+		server.Close()
+		rr := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(rr, newIdempotentRequest("key", "{}"))
+
+		// Assert
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, int64(1), calls.Load())
+	})
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should free expired keys", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		store := NewMemoryIdempotencyStore()
+		store.clock = clock
+		_, _, _ = store.Begin(ctx, "key", IdempotencyRecord{Fingerprint: "first"}, time.Minute)
+
+		// Act
+		clock.Advance(time.Minute)
+		record, ok, err := store.Begin(ctx, "key", IdempotencyRecord{Fingerprint: "second"}, time.Minute)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "second", record.Fingerprint)
+		assert.Len(t, store.entries, 1)
+	})
+}
+
+func TestRedisIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should share keys between replicas", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		first, err := NewRedisIdempotencyStoreFromURL("redis://" + server.Addr())
+		assert.NoError(t, err)
+		second, err := NewRedisIdempotencyStoreFromURL("redis://" + server.Addr())
+		assert.NoError(t, err)
+		t.Cleanup(func() {
+			_ = first.Close()
+			_ = second.Close()
+		})
+		_, _, _ = first.Begin(ctx, "key", IdempotencyRecord{Fingerprint: "request"}, time.Minute)
+		completed := IdempotencyRecord{Fingerprint: "request", Completed: true, Status: http.StatusCreated, Body: []byte("{}")}
+		_ = first.Complete(ctx, "key", completed, time.Hour)
+
+		// Act
+		record, ok, err := second.Begin(ctx, "key", IdempotencyRecord{Fingerprint: "request"}, time.Minute)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, completed, record)
+		assert.Equal(t, time.Hour, server.TTL(redisIdempotencyKeyPrefix+"key"))
+	})
+
+	t.Run("should free released keys", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		store := NewRedisIdempotencyStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+		store.ownsClient = true
+		t.Cleanup(func() {
+			_ = store.Close()
+		})
+		_, _, _ = store.Begin(ctx, "key", IdempotencyRecord{Fingerprint: "first"}, time.Minute)
+
+		// Act
+		_ = store.Release(ctx, "key")
+		_, ok, err := store.Begin(ctx, "key", IdempotencyRecord{Fingerprint: "second"}, time.Minute)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
@@ -0,0 +1,220 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type LogConfig struct {
+	// Level applies to the components without a level in ComponentLevels.
+	Level zapcore.Level `env:"LEVEL" default:"info"`
+	// Encoding is json or console.
+	Encoding string `env:"ENCODING" default:"json"`
+	// ComponentLevels override Level for components, e.g. store=debug,ratelimit=warn.
+	ComponentLevels []string `env:"COMPONENT_LEVELS"`
+}
+
+// componentLevel follows the root level until a level is set for the component.
+type componentLevel struct {
+	root     zap.AtomicLevel
+	level    zap.AtomicLevel
+	override atomic.Bool
+}
+
+func (level *componentLevel) Enabled(lvl zapcore.Level) bool {
+	if level.override.Load() {
+		return level.level.Enabled(lvl)
+	}
+
+	return level.root.Enabled(lvl)
+}
+
+// levelCore filters the entries of the wrapped core by a level that can change at runtime.
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (core *levelCore) Enabled(level zapcore.Level) bool {
+	return core.level.Enabled(level)
+}
+
+func (core *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: core.Core.With(fields), level: core.level}
+}
+
+func (core *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if core.Enabled(entry.Level) {
+		return checked.AddCore(entry, core)
+	}
+
+	return checked
+}
+
+// Loggers builds named component loggers that share one core, and have levels that can
+// be changed while the application runs.
+type Loggers struct {
+	mu         sync.Mutex
+	core       zapcore.Core
+	root       zap.AtomicLevel
+	components map[string]*componentLevel
+}
+
+// NewLoggers writes the logs to stderr in the encoding of the config.
+func NewLoggers(config LogConfig) (*Loggers, error) {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+	switch config.Encoding {
+	case "json", "":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("unknown log encoding %q, expected json or console", config.Encoding)
+	}
+
+	return NewLoggersWithCore(zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), zapcore.DebugLevel), config)
+}
+
+// NewLoggersWithCore writes the logs to core, which should accept every level.
+func NewLoggersWithCore(core zapcore.Core, config LogConfig) (*Loggers, error) {
+	loggers := &Loggers{
+		core:       core,
+		root:       zap.NewAtomicLevelAt(config.Level),
+		components: make(map[string]*componentLevel),
+	}
+
+	if err := loggers.Apply(config); err != nil {
+		return nil, err
+	}
+
+	return loggers, nil
+}
+
+// Logger returns the root logger for an empty component, or the logger named after the component.
+func (loggers *Loggers) Logger(component string) *zap.SugaredLogger {
+	if component == "" {
+		return zap.New(&levelCore{Core: loggers.core, level: loggers.root}, zap.AddCaller()).Sugar()
+	}
+
+	return zap.New(&levelCore{Core: loggers.core, level: loggers.component(component)}, zap.AddCaller()).
+		Named(component).
+		Sugar()
+}
+
+func (loggers *Loggers) component(name string) *componentLevel {
+	loggers.mu.Lock()
+	defer loggers.mu.Unlock()
+
+	level, exists := loggers.components[name]
+	if !exists {
+		level = &componentLevel{root: loggers.root, level: zap.NewAtomicLevel()}
+		loggers.components[name] = level
+	}
+
+	return level
+}
+
+// SetLevel changes the level of the component, or the root level for an empty component.
+// Components without a level of their own follow the root level.
+func (loggers *Loggers) SetLevel(component string, level zapcore.Level) {
+	if component == "" {
+		loggers.root.SetLevel(level)
+		return
+	}
+
+	componentLevel := loggers.component(component)
+	componentLevel.level.SetLevel(level)
+	componentLevel.override.Store(true)
+}
+
+// Levels returns the effective level of the root logger, under "", and of every component.
+func (loggers *Loggers) Levels() map[string]zapcore.Level {
+	loggers.mu.Lock()
+	defer loggers.mu.Unlock()
+
+	levels := map[string]zapcore.Level{"": loggers.root.Level()}
+	for name, level := range loggers.components {
+		if level.override.Load() {
+			levels[name] = level.level.Level()
+		} else {
+			levels[name] = loggers.root.Level()
+		}
+	}
+
+	return levels
+}
+
+// Validate checks the encoding and the component levels.
+func (config LogConfig) Validate() error {
+	var errs []error
+	if !slices.Contains([]string{"", "json", "console"}, config.Encoding) {
+		errs = append(errs, fmt.Errorf("unknown log encoding %q, expected json or console", config.Encoding))
+	}
+	if _, err := config.componentLevels(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (config LogConfig) componentLevels() (map[string]zapcore.Level, error) {
+	levels := make(map[string]zapcore.Level, len(config.ComponentLevels))
+
+	var errs []error
+	for _, item := range config.ComponentLevels {
+		component, value, found := strings.Cut(item, "=")
+		if !found || component == "" {
+			errs = append(errs, fmt.Errorf("component log level %q must look like component=level", item))
+			continue
+		}
+
+		level, err := zapcore.ParseLevel(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("component log level %q: %w", item, err))
+			continue
+		}
+		levels[component] = level
+	}
+
+	return levels, errors.Join(errs...)
+}
+
+// Apply sets the levels of the config. Components missing from it follow the root level again.
+// Nothing changes when a component level is invalid.
+func (loggers *Loggers) Apply(config LogConfig) error {
+	overrides, err := config.componentLevels()
+	if err != nil {
+		return err
+	}
+
+	loggers.root.SetLevel(config.Level)
+	for name, level := range overrides {
+		loggers.SetLevel(name, level)
+	}
+
+	loggers.mu.Lock()
+	defer loggers.mu.Unlock()
+	for name, level := range loggers.components {
+		if _, exists := overrides[name]; !exists {
+			level.override.Store(false)
+		}
+	}
+
+	return nil
+}
+
+// Sync flushes buffered log entries.
+func (loggers *Loggers) Sync() error {
+	return loggers.core.Sync()
+}
@@ -0,0 +1,77 @@
+package shared
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+)
+
+func TestLoggers(t *testing.T) {
+	t.Run("should log components at the root level until they get their own", func(t *testing.T) {
+		// Arrange
+		core, logs := observer.New(zapcore.DebugLevel)
+		loggers, err := NewLoggersWithCore(core, LogConfig{Level: zapcore.InfoLevel})
+		assert.NoError(t, err)
+		store := loggers.Logger("store")
+
+		// Act
+		store.Debug("hidden")
+		loggers.SetLevel("store", zapcore.DebugLevel)
+		store.Debug("shown")
+		loggers.Logger("").Debug("hidden")
+
+		// Assert
+		assert.Equal(t, 1, logs.Len())
+		assert.Equal(t, "shown", logs.All()[0].Message)
+		assert.Equal(t, "store", logs.All()[0].LoggerName)
+	})
+
+	t.Run("should apply the component levels of the config", func(t *testing.T) {
+		// Arrange
+		core, logs := observer.New(zapcore.DebugLevel)
+		loggers, err := NewLoggersWithCore(core, LogConfig{Level: zapcore.InfoLevel})
+		assert.NoError(t, err)
+		loggers.SetLevel("http", zapcore.DebugLevel)
+		ratelimit := loggers.Logger("ratelimit")
+
+		// Act
+		err = loggers.Apply(LogConfig{Level: zapcore.DebugLevel, ComponentLevels: []string{"ratelimit=error"}})
+		ratelimit.Warn("hidden")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 0, logs.Len())
+		assert.Equal(t, map[string]zapcore.Level{
+			"":          zapcore.DebugLevel,
+			"http":      zapcore.DebugLevel,
+			"ratelimit": zapcore.ErrorLevel,
+		}, loggers.Levels())
+	})
+
+	t.Run("should keep the levels when the config is invalid", func(t *testing.T) {
+		// Arrange
+		loggers, err := NewLoggersWithCore(zapcore.NewNopCore(), LogConfig{Level: zapcore.InfoLevel})
+		assert.NoError(t, err)
+
+		// Act
+		err = loggers.Apply(LogConfig{Level: zapcore.DebugLevel, ComponentLevels: []string{"store", "http=loud"}})
+
+		// Assert
+		assert.ErrorContains(t, err, `"store" must look like component=level`)
+		assert.ErrorContains(t, err, `"http=loud"`)
+		assert.Equal(t, zapcore.InfoLevel, loggers.Levels()[""])
+	})
+
+	t.Run("should reject unknown encodings", func(t *testing.T) {
+		// Arrange
+		config := LogConfig{Encoding: "xml"}
+
+		// Act
+		_, err := NewLoggers(config)
+
+		// Assert
+		assert.Error(t, err)
+		assert.Error(t, config.Validate())
+	})
+}
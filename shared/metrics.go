@@ -0,0 +1,103 @@
+package shared
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests answered before routing or matching no route, so raw
+// paths never become label values.
+const unmatchedRoute = "unmatched"
+
+// Metrics records HTTP and rate limiter metrics in its own registry, together with the
+// Go runtime and process collectors.
+type Metrics struct {
+	registry    *prometheus.Registry
+	requests    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	inFlight    prometheus.Gauge
+	rateLimited *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests being served.",
+		}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rate_limit_rejections_total",
+			Help: "Requests rejected by the rate limiter by policy.",
+		}, []string{"policy"}),
+	}
+
+	metrics.registry.MustRegister(
+		metrics.requests,
+		metrics.duration,
+		metrics.inFlight,
+		metrics.rateLimited,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return metrics
+}
+
+// Registerer registers application metrics, such as store latency, next to the HTTP ones.
+func (metrics *Metrics) Registerer() prometheus.Registerer {
+	return metrics.registry
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (metrics *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{Registry: metrics.registry})
+}
+
+// Middleware records every request under the chi route pattern it matched. It must be
+// installed on a chi router, as the pattern is known only once routing has finished.
+func (metrics *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.inFlight.Inc()
+		defer metrics.inFlight.Dec()
+
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+			metrics.requests.With(labels).Inc()
+			metrics.duration.With(labels).Observe(time.Since(start).Seconds())
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+func (metrics *Metrics) rateLimitRejected(policy string) {
+	metrics.rateLimited.WithLabelValues(policy).Inc()
+}
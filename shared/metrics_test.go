@@ -0,0 +1,65 @@
+package shared
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("should label requests by route pattern", func(t *testing.T) {
+		// Arrange
+		metrics := NewMetrics()
+		r := chi.NewRouter()
+		r.Use(metrics.Middleware)
+		r.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		// Act
+		for _, path := range []string{"/products/1", "/products/2", "/unknown"} {
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		// Assert
+		assert.Equal(t, 2.0, testutil.ToFloat64(metrics.requests.WithLabelValues("/products/{id}", http.MethodGet, "404")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues(unmatchedRoute, http.MethodGet, "404")))
+		assert.Equal(t, 2, testutil.CollectAndCount(metrics.duration))
+		assert.Equal(t, 0.0, testutil.ToFloat64(metrics.inFlight))
+	})
+
+	t.Run("should count rate limiter rejections per policy", func(t *testing.T) {
+		// Arrange
+		metrics := NewMetrics()
+		engine := newTestPolicyEngine(t, PolicyConfig{
+			Policies: []Policy{{Name: "strict", Limit: 1, Window: testConfig.TimeFrame}},
+		})
+		engine.SetMetrics(metrics)
+		handler := engine.RateLimiterMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		// Act
+		for i := 0; i < 3; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+
+		// Assert
+		assert.Equal(t, 2.0, testutil.ToFloat64(metrics.rateLimited.WithLabelValues("strict")))
+	})
+
+	t.Run("should expose runtime metrics", func(t *testing.T) {
+		// Arrange
+		metrics := NewMetrics()
+
+		// Act
+		rr := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.True(t, strings.Contains(rr.Body.String(), "go_goroutines"))
+	})
+}
@@ -1,18 +1,26 @@
 package shared
 
 import (
+	"go.uber.org/zap"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (limiter *FixedWindowRateLimiter) RateLimiterMiddleware() func(http.Handler) http.Handler {
+	return rateLimiterMiddleware(limiter, limiter.enabled, limiter.logger)
+}
+
+func rateLimiterMiddleware(limiter RateLimiter, enabled bool, logger *zap.SugaredLogger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			if limiter.enabled {
-				allowed, duration := limiter.Allow(r.RemoteAddr)
-				if !allowed {
-					limiter.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
-					w.Header().Set("Retry-After", duration.String())
-					http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			if enabled {
+				result := limiter.Allow(clientIP(r))
+				writeRateLimitHeaders(w, result)
+				if !result.Allowed {
+					logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
+					tooManyRequests(w, r, result)
 					return
 				}
 			}
@@ -21,3 +29,27 @@ func (limiter *FixedWindowRateLimiter) RateLimiterMiddleware() func(http.Handler
 		return http.HandlerFunc(fn)
 	}
 }
+
+// writeRateLimitHeaders describes the quota of the client with the RateLimit header
+// fields of the IETF httpapi working group draft.
+func writeRateLimitHeaders(w http.ResponseWriter, result Result) {
+	if result.Limit == 0 {
+		return
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+	w.Header().Set("RateLimit-Policy", strconv.Itoa(result.Limit)+";w="+strconv.Itoa(seconds(result.Window)))
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, result Result) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds(result.RetryAfter), 1)))
+
+	_ = WriteProblem(w, r, NewProblem(http.StatusTooManyRequests, "rate limit exceeded, retry later"))
+}
+
+// seconds rounds the duration up to whole seconds, as HTTP delay fields don't allow fractions.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
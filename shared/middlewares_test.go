@@ -4,21 +4,24 @@ import (
 	_ "github.com/stretchr/testify"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestRateLimiter(t *testing.T) {
 	middleware := NewFixedWindowRateLimiter(Config{
 		RequestPerTimeFrame: 5,
-		TimeFrame:           1,
+		TimeFrame:           time.Second,
 		Enabled:             true,
 	}, nil)
+	middleware.clock = newFakeClock()
+	defer middleware.Close()
 
 	t.Run("should run when limit is not exceeded", func(t *testing.T) {
 		// Arrange
 		ip := "localhost"
 
 		// Act
-		allowed, _ := middleware.Allow(ip)
+		allowed := middleware.Allow(ip).Allowed
 
 		// Assert
 		assert.True(t, allowed)
@@ -33,10 +36,10 @@ func TestRateLimiter(t *testing.T) {
 		}
 
 		// Act
-		allowed, duration := middleware.Allow(ip)
+		result := middleware.Allow(ip)
 
 		// Assert
-		assert.False(t, allowed)
-		assert.Equal(t, middleware.window, duration)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, middleware.window, result.RetryAfter)
 	})
 }
@@ -0,0 +1,480 @@
+package shared
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Identity names what a rate limit policy counts requests by.
+type Identity string
+
+const (
+	IdentityIP     Identity = "ip"
+	IdentityAPIKey Identity = "api_key"
+	IdentityUser   Identity = "user"
+	IdentityTenant Identity = "tenant"
+)
+
+// APIKeyHeader carries the API key of a partner. Keys are only trusted once the
+// service has validated them and stored them with WithAPIKey.
+const APIKeyHeader = "X-API-Key"
+
+// Policy is a named budget of Limit units per Window, tracked separately for every
+// value of its Identity.
+type Policy struct {
+	Name      string        `json:"name"`
+	Identity  Identity      `json:"identity"`
+	Limit     int           `json:"limit"`
+	Window    time.Duration `json:"window"`
+	Algorithm Algorithm     `json:"algorithm"`
+}
+
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	type policy Policy
+	aux := struct {
+		*policy
+		Window string `json:"window"`
+	}{policy: (*policy)(p)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	window, err := time.ParseDuration(aux.Window)
+	if err != nil {
+		return fmt.Errorf("policy %q: invalid window: %w", p.Name, err)
+	}
+	p.Window = window
+
+	return nil
+}
+
+// Rule charges requests matching Route and Methods Cost units of a policy. Route is a
+// path pattern where {name} matches a single segment and a trailing * matches the rest.
+// A rule applies only when the identity of its policy can be resolved, so rules for
+// API keys or users can precede a catch-all rule for anonymous clients.
+type Rule struct {
+	Route   string   `json:"route"`
+	Methods []string `json:"methods"`
+	Policy  string   `json:"policy"`
+	Cost    int      `json:"cost"`
+	Exempt  bool     `json:"exempt"`
+}
+
+type PolicyConfig struct {
+	Policies []Policy `json:"policies"`
+	Rules    []Rule   `json:"rules"`
+}
+
+// DefaultPolicyName names the policy created from Config when none are declared.
+const DefaultPolicyName = "default"
+
+// LoadPolicyConfig reads policies and rules from a JSON file.
+func LoadPolicyConfig(path string) (PolicyConfig, error) {
+	var config PolicyConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+type userContextKey struct{}
+
+// WithUser stores the authenticated user for user keyed rate limit policies.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+func UserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userContextKey{}).(string)
+	return user, ok && user != ""
+}
+
+type apiKeyContextKey struct{}
+
+// WithAPIKey stores a validated API key for API key keyed rate limit policies.
+func WithAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+func APIKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(string)
+	return key, ok && key != ""
+}
+
+type tenantContextKey struct{}
+
+// WithTenant stores the tenant of an authenticated client for tenant keyed rate limit policies.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// PolicyEngine applies the first matching rule to every request. Requests matching no
+// rule are not limited. Without declared policies every request costs one unit of a
+// default policy built from Config and keyed by client IP.
+type PolicyEngine struct {
+	set     atomic.Pointer[policySet]
+	logger  *zap.SugaredLogger
+	metrics *Metrics
+	// mu serializes reloads. The Redis-backed policies of all sets share one client per
+	// URL, kept across reloads and closed by Close.
+	mu      sync.Mutex
+	clients map[string]*redis.Client
+}
+
+// policySet is the immutable state of a PolicyEngine, swapped as a whole by Reload.
+type policySet struct {
+	rules    []Rule
+	policies map[string]Policy
+	limiters map[string]RateLimiter
+	enabled  bool
+	redisURL string
+}
+
+func NewPolicyEngine(policyConfig PolicyConfig, config Config, logger *zap.SugaredLogger) (*PolicyEngine, error) {
+	engine := &PolicyEngine{logger: logger, clients: make(map[string]*redis.Client)}
+
+	set, err := engine.newPolicySet(policyConfig, config)
+	if err != nil {
+		engine.releaseClients("")
+		return nil, err
+	}
+	engine.set.Store(set)
+
+	return engine, nil
+}
+
+// SetMetrics counts rejected requests per policy. It must be called before serving requests.
+func (engine *PolicyEngine) SetMetrics(metrics *Metrics) {
+	engine.metrics = metrics
+}
+
+// Reload replaces the policies and rules. Requests in flight finish with the previous
+// limiters. The counters kept in memory start over, while Redis-backed policies keep
+// theirs, as their keys outlive the limiters. On error the current policies are kept.
+func (engine *PolicyEngine) Reload(policyConfig PolicyConfig, config Config) error {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	set, err := engine.newPolicySet(policyConfig, config)
+	if err != nil {
+		engine.releaseClients(engine.set.Load().redisURL)
+		return err
+	}
+
+	err = engine.set.Swap(set).close()
+	engine.releaseClients(set.redisURL)
+
+	return err
+}
+
+// redisClient returns the client of url, creating it on first use.
+func (engine *PolicyEngine) redisClient(url string) (*redis.Client, error) {
+	if client, ok := engine.clients[url]; ok {
+		return client, nil
+	}
+
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(options)
+	engine.clients[url] = client
+
+	return client, nil
+}
+
+// releaseClients closes the Redis clients other than the one of keep.
+func (engine *PolicyEngine) releaseClients(keep string) {
+	for url, client := range engine.clients {
+		if url == keep {
+			continue
+		}
+		if err := client.Close(); err != nil {
+			engine.logger.Warnw("could not close the redis client", "error", err.Error())
+		}
+		delete(engine.clients, url)
+	}
+}
+
+func (engine *PolicyEngine) newPolicySet(policyConfig PolicyConfig, config Config) (*policySet, error) {
+	if len(policyConfig.Policies) == 0 {
+		policyConfig.Policies = []Policy{{
+			Name:      DefaultPolicyName,
+			Identity:  IdentityIP,
+			Limit:     config.RequestPerTimeFrame,
+			Window:    config.TimeFrame,
+			Algorithm: config.Algorithm,
+		}}
+	}
+	if len(policyConfig.Rules) == 0 {
+		policyConfig.Rules = []Rule{{Route: "/*", Policy: policyConfig.Policies[0].Name}}
+	}
+
+	set := &policySet{
+		policies: make(map[string]Policy),
+		limiters: make(map[string]RateLimiter),
+		enabled:  config.Enabled,
+		redisURL: config.RedisURL,
+	}
+
+	if err := set.build(engine, policyConfig, config); err != nil {
+		_ = set.close()
+		return nil, err
+	}
+
+	return set, nil
+}
+
+func (set *policySet) build(engine *PolicyEngine, policyConfig PolicyConfig, config Config) error {
+	for _, policy := range policyConfig.Policies {
+		if policy.Name == "" {
+			return errors.New("rate limit policy without a name")
+		}
+		if _, exists := set.policies[policy.Name]; exists {
+			return fmt.Errorf("rate limit policy %q declared twice", policy.Name)
+		}
+		if policy.Limit <= 0 || policy.Window <= 0 {
+			return fmt.Errorf("rate limit policy %q needs a positive limit and window", policy.Name)
+		}
+		if policy.Identity == "" {
+			policy.Identity = IdentityIP
+		}
+		if !slices.Contains([]Identity{IdentityIP, IdentityAPIKey, IdentityUser, IdentityTenant}, policy.Identity) {
+			return fmt.Errorf("rate limit policy %q has unknown identity %q", policy.Name, policy.Identity)
+		}
+		if policy.Algorithm == "" {
+			policy.Algorithm = config.Algorithm
+		}
+
+		limiter, err := engine.newLimiter(Config{
+			RequestPerTimeFrame: policy.Limit,
+			TimeFrame:           policy.Window,
+			Enabled:             true,
+			Algorithm:           policy.Algorithm,
+			MaxKeys:             config.MaxKeys,
+			RedisURL:            config.RedisURL,
+		})
+		if err != nil {
+			return fmt.Errorf("rate limit policy %q: %w", policy.Name, err)
+		}
+
+		set.policies[policy.Name] = policy
+		set.limiters[policy.Name] = limiter
+	}
+
+	for _, rule := range policyConfig.Rules {
+		if !strings.HasPrefix(rule.Route, "/") {
+			return fmt.Errorf("rate limit rule route %q must start with /", rule.Route)
+		}
+		if !rule.Exempt {
+			if _, exists := set.policies[rule.Policy]; !exists {
+				return fmt.Errorf("rate limit rule %q references unknown policy %q", rule.Route, rule.Policy)
+			}
+		}
+		if rule.Cost <= 0 {
+			rule.Cost = 1
+		}
+
+		set.rules = append(set.rules, rule)
+	}
+
+	return nil
+}
+
+// newLimiter creates the limiter of a policy like NewRateLimiter, on the shared client
+// of config.RedisURL when it is set.
+func (engine *PolicyEngine) newLimiter(config Config) (RateLimiter, error) {
+	if config.RedisURL == "" {
+		return NewRateLimiter(config, engine.logger)
+	}
+
+	client, err := engine.redisClient(config.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRedisRateLimiter(config, client, engine.logger)
+}
+
+// AllowRequest charges the request to the policy selected by the first matching rule and
+// reports the policy it was charged to. A cost of zero or less charges the cost declared
+// by the rule. Requests matching no rule or an exempt rule are allowed without charge.
+func (engine *PolicyEngine) AllowRequest(r *http.Request, cost int) (Result, string) {
+	set := engine.set.Load()
+	if !set.enabled {
+		return Result{Allowed: true}, ""
+	}
+
+	rule, key, ok := set.match(r)
+	if !ok || rule.Exempt {
+		return Result{Allowed: true}, ""
+	}
+
+	if cost <= 0 {
+		cost = rule.Cost
+	}
+
+	return set.limiters[rule.Policy].AllowN(key, cost), rule.Policy
+}
+
+// match returns the first rule applying to the request and the key the request is counted under.
+func (set *policySet) match(r *http.Request) (Rule, string, bool) {
+	for _, rule := range set.rules {
+		if !rule.matches(r) {
+			continue
+		}
+		if rule.Exempt {
+			return rule, "", true
+		}
+
+		policy := set.policies[rule.Policy]
+		identity, ok := resolveIdentity(r, policy.Identity)
+		if !ok {
+			continue
+		}
+
+		return rule, policy.Name + ":" + string(policy.Identity) + ":" + identity, true
+	}
+
+	return Rule{}, "", false
+}
+
+// ChargeRequest charges the request like AllowRequest and writes the rate limit headers.
+// It answers a rejected request with 429 and reports whether the request may proceed.
+// Handlers knowing the cost of a request only after reading it, e.g. from the
+// complexity of a query, charge it on top of the cost charged by the middleware.
+func (engine *PolicyEngine) ChargeRequest(w http.ResponseWriter, r *http.Request, cost int) bool {
+	result := engine.Charge(r, cost)
+	writeRateLimitHeaders(w, result)
+	if !result.Allowed {
+		tooManyRequests(w, r, result)
+		return false
+	}
+
+	return true
+}
+
+// Charge charges the request like AllowRequest, and logs and counts a rejection. It
+// serves transports that report the result themselves, e.g. gRPC calls described as
+// requests to their full method name.
+func (engine *PolicyEngine) Charge(r *http.Request, cost int) Result {
+	result, policy := engine.AllowRequest(r, cost)
+	if !result.Allowed {
+		engine.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path, "policy", policy)
+		if engine.metrics != nil {
+			engine.metrics.rateLimitRejected(policy)
+		}
+	}
+
+	return result
+}
+
+func (engine *PolicyEngine) RateLimiterMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if engine.ChargeRequest(w, r, 0) {
+				next.ServeHTTP(w, r)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func (engine *PolicyEngine) Close() error {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	err := engine.set.Load().close()
+	engine.releaseClients("")
+
+	return err
+}
+
+func (set *policySet) close() error {
+	var errs []error
+	for _, limiter := range set.limiters {
+		errs = append(errs, limiter.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (rule Rule) matches(r *http.Request) bool {
+	if len(rule.Methods) > 0 && !slices.ContainsFunc(rule.Methods, func(method string) bool {
+		return strings.EqualFold(method, r.Method)
+	}) {
+		return false
+	}
+
+	return matchRoute(rule.Route, r.URL.Path)
+}
+
+func matchRoute(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return len(patternSegments) == len(pathSegments)
+}
+
+func resolveIdentity(r *http.Request, identity Identity) (string, bool) {
+	switch identity {
+	case IdentityAPIKey:
+		key, ok := APIKeyFromContext(r.Context())
+		if !ok {
+			return "", false
+		}
+		// API keys are secrets, so only their hash is kept by the limiters.
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:]), true
+	case IdentityUser:
+		return UserFromContext(r.Context())
+	case IdentityTenant:
+		return TenantFromContext(r.Context())
+	default:
+		return clientIP(r), true
+	}
+}
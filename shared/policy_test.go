@@ -0,0 +1,341 @@
+package shared
+
+import (
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestPolicyEngine(t *testing.T, config PolicyConfig) *PolicyEngine {
+	t.Helper()
+
+	engine, err := NewPolicyEngine(config, testConfig, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = engine.Close()
+	})
+
+	return engine
+}
+
+func TestPolicyEngine(t *testing.T) {
+	config := PolicyConfig{
+		Policies: []Policy{
+			{Name: "anonymous", Identity: IdentityIP, Limit: 10, Window: time.Minute},
+			{Name: "partners", Identity: IdentityAPIKey, Limit: 100, Window: time.Minute},
+		},
+		Rules: []Rule{
+			{Route: "/healthcheck", Exempt: true},
+			{Route: "/products", Methods: []string{http.MethodGet}, Policy: "anonymous", Cost: 5},
+			{Route: "/products/{id}", Policy: "partners"},
+			{Route: "/*", Policy: "anonymous"},
+		},
+	}
+
+	t.Run("should charge the cost of the matching rule", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+
+		// Act
+		first, policy := engine.AllowRequest(req, 0)
+		second, _ := engine.AllowRequest(req, 0)
+		third, _ := engine.AllowRequest(req, 0)
+
+		// Assert
+		assert.Equal(t, "anonymous", policy)
+		assert.True(t, first.Allowed)
+		assert.True(t, second.Allowed)
+		assert.False(t, third.Allowed)
+	})
+
+	t.Run("should not limit exempt routes", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		req := httptest.NewRequest(http.MethodGet, "/healthcheck", nil)
+
+		// Act
+		allowed := 0
+		for i := 0; i < 20; i++ {
+			if result, _ := engine.AllowRequest(req, 0); result.Allowed {
+				allowed++
+			}
+		}
+
+		// Assert
+		assert.Equal(t, 20, allowed)
+	})
+
+	t.Run("should skip rules whose identity is missing", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		anonymous := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+		partner := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+		partner = partner.WithContext(WithAPIKey(partner.Context(), "secret"))
+
+		// Act
+		_, anonymousPolicy := engine.AllowRequest(anonymous, 0)
+		_, partnerPolicy := engine.AllowRequest(partner, 0)
+
+		// Assert
+		assert.Equal(t, "anonymous", anonymousPolicy)
+		assert.Equal(t, "partners", partnerPolicy)
+	})
+
+	t.Run("should ignore an API key that wasn't validated", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+		req.Header.Set(APIKeyHeader, "made-up")
+
+		// Act
+		_, policy := engine.AllowRequest(req, 0)
+
+		// Assert
+		assert.Equal(t, "anonymous", policy)
+	})
+
+	t.Run("should count every identity separately", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		first := httptest.NewRequest(http.MethodPost, "/products", nil)
+		first.RemoteAddr = "10.0.0.1:1234"
+		second := httptest.NewRequest(http.MethodPost, "/products", nil)
+		second.RemoteAddr = "10.0.0.2:1234"
+
+		// Act
+		for i := 0; i < 10; i++ {
+			engine.AllowRequest(first, 0)
+		}
+		firstResult, _ := engine.AllowRequest(first, 0)
+		secondResult, _ := engine.AllowRequest(second, 0)
+
+		// Assert
+		assert.False(t, firstResult.Allowed)
+		assert.True(t, secondResult.Allowed)
+	})
+
+	t.Run("should count every connection of a client together", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+
+		// Act
+		for i := 0; i < 10; i++ {
+			req.RemoteAddr = fmt.Sprintf("10.0.0.1:%d", 1000+i)
+			engine.AllowRequest(req, 0)
+		}
+		req.RemoteAddr = "10.0.0.1:2000"
+		result, _ := engine.AllowRequest(req, 0)
+
+		// Assert
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("should reject the request in the middleware", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		handler := engine.RateLimiterMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		for i := 0; i < 2; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		// Act
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		// Assert
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+		assert.Equal(t, "10", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "60", rr.Header().Get("RateLimit-Reset"))
+		assert.Equal(t, "10;w=60", rr.Header().Get("RateLimit-Policy"))
+		assert.JSONEq(t, `{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"rate limit exceeded, retry later"}`, rr.Body.String())
+	})
+
+	t.Run("should charge the cost known to the handler", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		allowed := httptest.NewRecorder()
+		rejected := httptest.NewRecorder()
+
+		// Act
+		first := engine.ChargeRequest(allowed, req, 8)
+		second := engine.ChargeRequest(rejected, req, 3)
+
+		// Assert
+		assert.True(t, first)
+		assert.Equal(t, "2", allowed.Header().Get("RateLimit-Remaining"))
+		assert.False(t, second)
+		assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+	})
+
+	t.Run("should describe the remaining quota on allowed requests", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		handler := engine.RateLimiterMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+
+		// Act
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "10", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "9", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "10;w=60", rr.Header().Get("RateLimit-Policy"))
+		assert.Empty(t, rr.Header().Get("Retry-After"))
+	})
+
+	t.Run("should not describe a quota for exempt routes", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, config)
+		handler := engine.RateLimiterMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodGet, "/healthcheck", nil)
+
+		// Act
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		// Assert
+		assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("should reject rules referencing unknown policies", func(t *testing.T) {
+		// Arrange
+		invalid := PolicyConfig{Rules: []Rule{{Route: "/*", Policy: "missing"}}}
+
+		// Act
+		_, err := NewPolicyEngine(invalid, testConfig, nil)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestMatchRoute(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"/products", "/products", true},
+		{"/products", "/products/", true},
+		{"/products", "/products/1", false},
+		{"/products/{id}", "/products/1", true},
+		{"/products/{id}", "/products", false},
+		{"/swagger/*", "/swagger/index.html", true},
+		{"/swagger/*", "/swagger", true},
+		{"/*", "/anything/at/all", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.path, func(t *testing.T) {
+			assert.Equal(t, test.matches, matchRoute(test.pattern, test.path))
+		})
+	}
+}
+
+func TestLoadPolicyConfig(t *testing.T) {
+	t.Run("should parse policies and rules", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "policies.json")
+		content := `{
+			"policies": [{"name": "anonymous", "identity": "ip", "limit": 10, "window": "1m"}],
+			"rules": [{"route": "/*", "policy": "anonymous", "cost": 2}]
+		}`
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		config, err := LoadPolicyConfig(path)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, config.Policies[0].Window)
+		assert.Equal(t, 2, config.Rules[0].Cost)
+	})
+}
+
+func TestPolicyEngineReload(t *testing.T) {
+	t.Run("should apply the new policies", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, PolicyConfig{})
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+
+		// Act
+		err := engine.Reload(PolicyConfig{
+			Policies: []Policy{{Name: "strict", Limit: 1, Window: time.Minute}},
+		}, testConfig)
+		first, policy := engine.AllowRequest(req, 0)
+		second, _ := engine.AllowRequest(req, 0)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "strict", policy)
+		assert.True(t, first.Allowed)
+		assert.False(t, second.Allowed)
+	})
+
+	t.Run("should share one redis client and keep its counters across reloads", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		config := testConfig
+		config.Enabled = true
+		config.RedisURL = "redis://" + server.Addr()
+		policies := PolicyConfig{Policies: []Policy{
+			{Name: "strict", Limit: 1, Window: time.Minute},
+			{Name: "other", Limit: 1, Window: time.Minute},
+		}}
+		engine, err := NewPolicyEngine(policies, config, zap.NewNop().Sugar())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = engine.Close()
+		})
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+
+		// Act
+		first, _ := engine.AllowRequest(req, 0)
+		reloadErr := engine.Reload(policies, config)
+		second, _ := engine.AllowRequest(req, 0)
+
+		// Assert
+		assert.NoError(t, reloadErr)
+		assert.True(t, first.Allowed)
+		assert.False(t, second.Allowed)
+		assert.Len(t, engine.clients, 1)
+	})
+
+	t.Run("should keep the current policies when the new ones are invalid", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, PolicyConfig{})
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+
+		// Act
+		err := engine.Reload(PolicyConfig{
+			Rules: []Rule{{Route: "/*", Policy: "missing"}},
+		}, testConfig)
+		_, policy := engine.AllowRequest(req, 0)
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, DefaultPolicyName, policy)
+	})
+}
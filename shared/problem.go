@@ -0,0 +1,48 @@
+package shared
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response. Type is about:blank, so Title is the
+// text of the status code.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the ID of the request, also found in the logs.
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes an invalid field of the request, named as in the JSON body or query.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func NewProblem(status int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// WriteProblem writes the problem, using the request ID of r as its instance.
+func WriteProblem(w http.ResponseWriter, r *http.Request, problem Problem) error {
+	if problem.Instance == "" {
+		problem.Instance = middleware.GetReqID(r.Context())
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+
+	return json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,33 @@
+package shared
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteProblem(t *testing.T) {
+	t.Run("should use the request id as the instance", func(t *testing.T) {
+		// Arrange
+		handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = WriteProblem(w, r, NewProblem(http.StatusNotFound, "the requested resource could not be found"))
+		}))
+		rr := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		// Assert
+		var problem Problem
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "about:blank", problem.Type)
+		assert.Equal(t, "Not Found", problem.Title)
+		assert.Equal(t, http.StatusNotFound, problem.Status)
+		assert.NotEmpty(t, problem.Instance)
+	})
+}
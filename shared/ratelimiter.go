@@ -1,67 +1,165 @@
 package shared
 
 import (
+	"fmt"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"net/http"
-	"sync"
 	"time"
 )
 
+// Result describes a rate limiting decision and the quota left afterwards.
+type Result struct {
+	Allowed bool
+	// Limit is the quota per Window. It is zero when the request was not limited.
+	Limit     int
+	Window    time.Duration
+	Remaining int
+	// Reset is the time until the quota is replenished.
+	Reset time.Duration
+	// RetryAfter is the time until a rejected request could be allowed.
+	RetryAfter time.Duration
+}
+
 type RateLimiter interface {
-	Allow(key string) (bool, time.Duration)
+	Allow(key string) Result
+	// AllowN reports whether a request costing n units of the limit is allowed.
+	AllowN(key string, n int) Result
 	RateLimiterMiddleware() func(http.Handler) http.Handler
+	Close() error
 }
 
+type Algorithm string
+
+const (
+	FixedWindow          Algorithm = "fixed_window"
+	TokenBucket          Algorithm = "token_bucket"
+	SlidingWindowLog     Algorithm = "sliding_window_log"
+	SlidingWindowCounter Algorithm = "sliding_window_counter"
+)
+
 type Config struct {
-	RequestPerTimeFrame int
-	TimeFrame           time.Duration
-	Enabled             bool
+	RequestPerTimeFrame int           `env:"MAX_REQUESTS" default:"100"`
+	TimeFrame           time.Duration `env:"WINDOW" default:"1m"`
+	Enabled             bool          `env:"ENABLED" default:"false"`
+	Algorithm           Algorithm     `env:"ALGORITHM" default:"fixed_window"`
+	MaxKeys             int           `env:"MAX_KEYS" default:"100000"`
+	// RedisURL, when set, shares the limits between replicas through Redis.
+	RedisURL string `env:"REDIS_URL" secret:"true"`
+}
+
+// NewRateLimiter creates the rate limiter selected by config.Algorithm, defaulting to a
+// fixed window. The limiter is backed by Redis when config.RedisURL is set.
+func NewRateLimiter(config Config, logger *zap.SugaredLogger) (RateLimiter, error) {
+	if config.RedisURL != "" {
+		options, err := redis.ParseURL(config.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+
+		client := redis.NewClient(options)
+		limiter, err := NewRedisRateLimiter(config, client, logger)
+		if err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+		limiter.ownsClient = true
+
+		return limiter, nil
+	}
+
+	switch config.Algorithm {
+	case FixedWindow, "":
+		return NewFixedWindowRateLimiter(config, logger), nil
+	case TokenBucket:
+		return NewTokenBucketRateLimiter(config, logger), nil
+	case SlidingWindowLog:
+		return NewSlidingWindowLogRateLimiter(config, logger), nil
+	case SlidingWindowCounter:
+		return NewSlidingWindowCounterRateLimiter(config, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limiter algorithm %q", config.Algorithm)
+	}
 }
 
+type fixedWindow struct {
+	start time.Time
+	count int
+}
+
+// FixedWindowRateLimiter allows RequestPerTimeFrame requests per client in windows of
+// TimeFrame starting at the client's first request.
 type FixedWindowRateLimiter struct {
-	sync.RWMutex
-	clients map[string]int
+	clients *bucketStore[fixedWindow]
 	limit   int
 	window  time.Duration
 	enabled bool
 	logger  *zap.SugaredLogger
+	clock   Clock
 }
 
 func NewFixedWindowRateLimiter(config Config, logger *zap.SugaredLogger) *FixedWindowRateLimiter {
-	return &FixedWindowRateLimiter{
-		clients: make(map[string]int),
+	limiter := &FixedWindowRateLimiter{
 		limit:   config.RequestPerTimeFrame,
 		window:  config.TimeFrame,
 		enabled: config.Enabled,
 		logger:  logger,
+		clock:   systemClock{},
 	}
+	limiter.clients = newBucketStore(config.MaxKeys, config.TimeFrame, limiter.now, limiter.expired)
+
+	return limiter
 }
 
-func (limiter *FixedWindowRateLimiter) Allow(key string) (bool, time.Duration) {
-	limiter.Lock()
-	defer limiter.Unlock()
+func (limiter *FixedWindowRateLimiter) Allow(key string) Result {
+	return limiter.AllowN(key, 1)
+}
 
+func (limiter *FixedWindowRateLimiter) AllowN(key string, n int) Result {
 	if !limiter.enabled {
-		return true, 0
+		return Result{Allowed: true}
+	}
+
+	limiter.clients.Lock()
+	defer limiter.clients.Unlock()
+
+	now := limiter.clock.Now()
+	window := limiter.clients.get(key, func(window *fixedWindow) {
+		window.start = now
+	})
+
+	if limiter.expired(window, now) {
+		window.start = now
+		window.count = 0
 	}
 
-	_, exist := limiter.clients[key]
-	if !exist {
-		go limiter.reset(key)
+	result := Result{
+		Limit:  limiter.limit,
+		Window: limiter.window,
+		Reset:  window.start.Add(limiter.window).Sub(now),
 	}
 
-	limiter.clients[key]++
-	if limiter.clients[key] > limiter.limit {
-		return false, limiter.window
+	if window.count+n > limiter.limit {
+		result.Remaining = max(limiter.limit-window.count, 0)
+		result.RetryAfter = result.Reset
+		return result
 	}
 
-	return true, 0
+	window.count += n
+	result.Allowed = true
+	result.Remaining = limiter.limit - window.count
+
+	return result
 }
 
-func (limiter *FixedWindowRateLimiter) reset(key string) {
-	time.Sleep(limiter.window)
-	limiter.Lock()
-	defer limiter.Unlock()
+func (limiter *FixedWindowRateLimiter) Close() error {
+	return limiter.clients.Close()
+}
+
+func (limiter *FixedWindowRateLimiter) now() time.Time {
+	return limiter.clock.Now()
+}
 
-	delete(limiter.clients, key)
+func (limiter *FixedWindowRateLimiter) expired(window *fixedWindow, now time.Time) bool {
+	return !now.Before(window.start.Add(limiter.window))
 }
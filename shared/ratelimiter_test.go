@@ -0,0 +1,331 @@
+package shared
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func allowN(limiter RateLimiter, key string, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if limiter.Allow(key).Allowed {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+var testConfig = Config{
+	RequestPerTimeFrame: 10,
+	TimeFrame:           time.Minute,
+	Enabled:             true,
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	t.Run("should create the selected algorithm", func(t *testing.T) {
+		// Arrange
+		config := testConfig
+		config.Algorithm = TokenBucket
+
+		// Act
+		limiter, err := NewRateLimiter(config, nil)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.IsType(t, &TokenBucketRateLimiter{}, limiter)
+		assert.NoError(t, limiter.Close())
+	})
+
+	t.Run("should reject an unknown algorithm", func(t *testing.T) {
+		// Arrange
+		config := testConfig
+		config.Algorithm = "leaky_bucket"
+
+		// Act
+		_, err := NewRateLimiter(config, nil)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestTokenBucketRateLimiter(t *testing.T) {
+	t.Run("should allow a burst up to the capacity", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewTokenBucketRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+
+		// Act
+		allowed := allowN(limiter, "client", 15)
+
+		// Assert
+		assert.Equal(t, 10, allowed)
+	})
+
+	t.Run("should refill one token per interval", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewTokenBucketRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+		allowN(limiter, "client", 10)
+
+		// Act
+		retryAfter := limiter.Allow("client").RetryAfter
+		clock.Advance(6 * time.Second)
+		allowed := allowN(limiter, "client", 2)
+
+		// Assert
+		assert.Equal(t, 6*time.Second, retryAfter)
+		assert.Equal(t, 1, allowed)
+	})
+
+	t.Run("should not allow a double burst across a window boundary", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewTokenBucketRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+		clock.Advance(59 * time.Second)
+
+		// Act
+		allowed := allowN(limiter, "client", 10)
+		clock.Advance(2 * time.Second)
+		allowed += allowN(limiter, "client", 10)
+
+		// Assert
+		assert.Equal(t, 10, allowed)
+	})
+}
+
+func TestSlidingWindowLogRateLimiter(t *testing.T) {
+	t.Run("should not allow a double burst across a window boundary", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewSlidingWindowLogRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+		clock.Advance(59 * time.Second)
+
+		// Act
+		allowed := allowN(limiter, "client", 10)
+		clock.Advance(2 * time.Second)
+		allowed += allowN(limiter, "client", 10)
+
+		// Assert
+		assert.Equal(t, 10, allowed)
+	})
+
+	t.Run("should allow requests again once the oldest leaves the window", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewSlidingWindowLogRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+		allowN(limiter, "client", 5)
+		clock.Advance(30 * time.Second)
+		allowN(limiter, "client", 5)
+
+		// Act
+		retryAfter := limiter.Allow("client").RetryAfter
+		clock.Advance(retryAfter)
+		allowed := allowN(limiter, "client", 10)
+
+		// Assert
+		assert.Equal(t, 30*time.Second, retryAfter)
+		assert.Equal(t, 5, allowed)
+	})
+}
+
+func TestSlidingWindowCounterRateLimiter(t *testing.T) {
+	t.Run("should weight the previous window by its overlap", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewSlidingWindowCounterRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+		clock.Advance(59 * time.Second)
+		allowN(limiter, "client", 10)
+
+		// Act
+		clock.Advance(31 * time.Second)
+		allowed := allowN(limiter, "client", 10)
+
+		// Assert
+		assert.Equal(t, 5, allowed)
+	})
+
+	t.Run("should not allow a double burst across a window boundary", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewSlidingWindowCounterRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+		clock.Advance(59 * time.Second)
+
+		// Act
+		allowed := allowN(limiter, "client", 10)
+		clock.Advance(2 * time.Second)
+		allowed += allowN(limiter, "client", 10)
+
+		// Assert
+		assert.Equal(t, 10, allowed)
+	})
+
+	t.Run("should allow a full window once the previous one has expired", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewSlidingWindowCounterRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+		allowN(limiter, "client", 10)
+
+		// Act
+		clock.Advance(2 * time.Minute)
+		allowed := allowN(limiter, "client", 15)
+
+		// Assert
+		assert.Equal(t, 10, allowed)
+	})
+}
+
+func TestFixedWindowRateLimiter(t *testing.T) {
+	t.Run("should start a new window once the previous one has expired", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewFixedWindowRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+		allowN(limiter, "client", 10)
+
+		// Act
+		clock.Advance(30 * time.Second)
+		retryAfter := limiter.Allow("client").RetryAfter
+		clock.Advance(retryAfter)
+		allowed := allowN(limiter, "client", 15)
+
+		// Assert
+		assert.Equal(t, 30*time.Second, retryAfter)
+		assert.Equal(t, 10, allowed)
+	})
+
+	t.Run("should evict the least recently used client when full", func(t *testing.T) {
+		// Arrange
+		config := testConfig
+		config.MaxKeys = 2
+		limiter := NewFixedWindowRateLimiter(config, nil)
+		defer limiter.Close()
+		limiter.clock = newFakeClock()
+		allowN(limiter, "first", 10)
+		allowN(limiter, "second", 10)
+		allowN(limiter, "first", 1)
+
+		// Act
+		allowN(limiter, "third", 1)
+
+		// Assert
+		assert.Equal(t, 2, limiter.clients.len())
+		assert.Equal(t, 0, allowN(limiter, "first", 1))
+		assert.Equal(t, 1, allowN(limiter, "second", 1))
+	})
+
+	t.Run("should sweep expired clients", func(t *testing.T) {
+		// Arrange
+		clock := newFakeClock()
+		limiter := NewFixedWindowRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		limiter.clock = clock
+		allowN(limiter, "first", 1)
+		clock.Advance(30 * time.Second)
+		allowN(limiter, "second", 1)
+
+		// Act
+		clock.Advance(45 * time.Second)
+		limiter.clients.sweep()
+
+		// Assert
+		assert.Equal(t, 1, limiter.clients.len())
+	})
+
+	t.Run("should not start a goroutine per client", func(t *testing.T) {
+		// Arrange
+		limiter := NewFixedWindowRateLimiter(testConfig, nil)
+		defer limiter.Close()
+		goroutines := runtime.NumGoroutine()
+
+		// Act
+		for i := 0; i < 1000; i++ {
+			limiter.Allow(fmt.Sprintf("client-%d", i))
+		}
+
+		// Assert
+		assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+	})
+
+	t.Run("should allow closing more than once", func(t *testing.T) {
+		// Arrange
+		limiter := NewFixedWindowRateLimiter(testConfig, nil)
+
+		// Act
+		first := limiter.Close()
+		second := limiter.Close()
+
+		// Assert
+		assert.NoError(t, first)
+		assert.NoError(t, second)
+	})
+}
+
+func BenchmarkRateLimiterDistinctKeys(b *testing.B) {
+	keys := make([]string, 1_000_000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+	}
+
+	for _, algorithm := range []Algorithm{FixedWindow, TokenBucket, SlidingWindowLog, SlidingWindowCounter} {
+		for _, maxKeys := range []int{len(keys), DefaultMaxKeys} {
+			b.Run(fmt.Sprintf("%s/max_keys=%d", algorithm, maxKeys), func(b *testing.B) {
+				config := testConfig
+				config.Algorithm = algorithm
+				config.MaxKeys = maxKeys
+
+				goroutines := runtime.NumGoroutine()
+				limiter, err := NewRateLimiter(config, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer limiter.Close()
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					limiter.Allow(keys[i%len(keys)])
+				}
+				b.StopTimer()
+
+				b.ReportMetric(float64(runtime.NumGoroutine()-goroutines), "goroutines")
+			})
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// RedisIdempotencyStore shares the keys between replicas. Begin takes a key with SET NX,
+// so only one replica serves a request and the others see it in progress.
+type RedisIdempotencyStore struct {
+	client     redis.UniversalClient
+	ownsClient bool
+}
+
+func NewRedisIdempotencyStore(client redis.UniversalClient) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{client: client}
+}
+
+// NewRedisIdempotencyStoreFromURL creates a store with its own client, closed by Close.
+func NewRedisIdempotencyStoreFromURL(url string) (*RedisIdempotencyStore, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewRedisIdempotencyStore(redis.NewClient(options))
+	store.ownsClient = true
+
+	return store, nil
+}
+
+func (store *RedisIdempotencyStore) Begin(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return record, false, err
+	}
+
+	// The stored record may expire between SET NX and GET, so the key is taken again.
+	for range 2 {
+		ok, err := store.client.SetNX(ctx, redisIdempotencyKeyPrefix+key, encoded, ttl).Result()
+		if err != nil {
+			return record, false, err
+		}
+		if ok {
+			return record, true, nil
+		}
+
+		stored, err := store.client.Get(ctx, redisIdempotencyKeyPrefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return record, false, err
+		}
+
+		var existing IdempotencyRecord
+		if err := json.Unmarshal(stored, &existing); err != nil {
+			return record, false, err
+		}
+
+		return existing, false, nil
+	}
+
+	return record, false, errors.New("idempotency key expired while it was read")
+}
+
+func (store *RedisIdempotencyStore) Complete(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return store.client.Set(ctx, redisIdempotencyKeyPrefix+key, encoded, ttl).Err()
+}
+
+func (store *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return store.client.Del(ctx, redisIdempotencyKeyPrefix+key).Err()
+}
+
+// Close closes the Redis client when the store created it.
+func (store *RedisIdempotencyStore) Close() error {
+	if store.ownsClient {
+		return store.client.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	redisKeyPrefix = "ratelimit:"
+	redisTimeout   = 100 * time.Millisecond
+	// redisRetryInterval is how long the limiter keeps using the local fallback after Redis failed.
+	redisRetryInterval = 5 * time.Second
+)
+
+// fixedWindowScript counts request costs in a window that starts with the first request.
+// It returns whether the request is allowed, the milliseconds until the window resets
+// and the units used in the window.
+var fixedWindowScript = redis.NewScript(`
+local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+if count == 0 then
+	redis.call("SET", KEYS[1], 0, "PX", ARGV[2])
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if count + tonumber(ARGV[3]) > tonumber(ARGV[1]) then
+	return {0, ttl, count}
+end
+count = redis.call("INCRBY", KEYS[1], ARGV[3])
+return {1, ttl, count}
+`)
+
+// tokenBucketScript refills the bucket based on the Redis server time, so replicas don't
+// need synchronised clocks. It returns whether the request is allowed, the microseconds
+// until enough tokens are available, the tokens left and the microseconds until the
+// bucket is full.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local refill = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1])
+local updated = tonumber(bucket[2])
+if tokens == nil or updated == nil then
+	tokens = capacity
+	updated = now
+end
+
+tokens = math.min(capacity, tokens + math.max(now - updated, 0) / refill)
+
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	retry = math.ceil((cost - tokens) * refill)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity * refill / 1000))
+return {allowed, retry, math.floor(tokens), math.ceil((capacity - tokens) * refill)}
+`)
+
+// RedisRateLimiter shares the limit between all replicas by keeping the counters in
+// Redis. Each decision is a single atomic Lua script. While Redis is unreachable the
+// limiter falls back to a local limiter of the same algorithm.
+type RedisRateLimiter struct {
+	client           redis.UniversalClient
+	ownsClient       bool
+	algorithm        Algorithm
+	limit            int
+	window           time.Duration
+	enabled          bool
+	fallback         RateLimiter
+	logger           *zap.SugaredLogger
+	unavailableUntil atomic.Int64
+}
+
+// NewRedisRateLimiter creates a Redis backed limiter for the fixed window or token bucket algorithm.
+func NewRedisRateLimiter(config Config, client redis.UniversalClient, logger *zap.SugaredLogger) (*RedisRateLimiter, error) {
+	algorithm := config.Algorithm
+	if algorithm == "" {
+		algorithm = FixedWindow
+	}
+	if algorithm != FixedWindow && algorithm != TokenBucket {
+		return nil, fmt.Errorf("rate limiter algorithm %q is not supported with redis", config.Algorithm)
+	}
+
+	localConfig := config
+	localConfig.RedisURL = ""
+	fallback, err := NewRateLimiter(localConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisRateLimiter{
+		client:    client,
+		algorithm: algorithm,
+		limit:     config.RequestPerTimeFrame,
+		window:    config.TimeFrame,
+		enabled:   config.Enabled,
+		fallback:  fallback,
+		logger:    logger,
+	}, nil
+}
+
+func (limiter *RedisRateLimiter) Allow(key string) Result {
+	return limiter.AllowN(key, 1)
+}
+
+func (limiter *RedisRateLimiter) AllowN(key string, n int) Result {
+	if !limiter.enabled {
+		return Result{Allowed: true}
+	}
+
+	if time.Now().UnixNano() < limiter.unavailableUntil.Load() {
+		return limiter.fallback.AllowN(key, n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	result, err := limiter.run(ctx, key, n)
+	if err != nil {
+		limiter.unavailableUntil.Store(time.Now().Add(redisRetryInterval).UnixNano())
+		limiter.logger.Warnw("redis rate limiter unavailable, using local limits", "error", err.Error())
+		return limiter.fallback.AllowN(key, n)
+	}
+
+	return result
+}
+
+func (limiter *RedisRateLimiter) run(ctx context.Context, key string, n int) (Result, error) {
+	redisKey := redisKeyPrefix + string(limiter.algorithm) + ":" + key
+	result := Result{Limit: limiter.limit, Window: limiter.window}
+
+	switch limiter.algorithm {
+	case TokenBucket:
+		refill := max(limiter.window.Microseconds()/int64(max(limiter.limit, 1)), 1)
+		values, err := tokenBucketScript.Run(ctx, limiter.client, []string{redisKey}, limiter.limit, refill, n).Int64Slice()
+		if err != nil {
+			return result, err
+		}
+		if len(values) != 4 {
+			return result, fmt.Errorf("unexpected rate limiter script result %v", values)
+		}
+
+		result.Allowed = values[0] == 1
+		result.RetryAfter = time.Duration(values[1]) * time.Microsecond
+		result.Remaining = int(values[2])
+		result.Reset = time.Duration(values[3]) * time.Microsecond
+	default:
+		values, err := fixedWindowScript.Run(ctx, limiter.client, []string{redisKey}, limiter.limit, limiter.window.Milliseconds(), n).Int64Slice()
+		if err != nil {
+			return result, err
+		}
+		if len(values) != 3 {
+			return result, fmt.Errorf("unexpected rate limiter script result %v", values)
+		}
+
+		result.Allowed = values[0] == 1
+		result.Reset = time.Duration(values[1]) * time.Millisecond
+		result.Remaining = max(limiter.limit-int(values[2]), 0)
+		if !result.Allowed {
+			result.RetryAfter = result.Reset
+		}
+	}
+
+	return result, nil
+}
+
+func (limiter *RedisRateLimiter) RateLimiterMiddleware() func(http.Handler) http.Handler {
+	return rateLimiterMiddleware(limiter, limiter.enabled, limiter.logger)
+}
+
+// Close releases the local fallback, and the Redis client when the limiter created it.
+func (limiter *RedisRateLimiter) Close() error {
+	err := limiter.fallback.Close()
+	if limiter.ownsClient {
+		if closeErr := limiter.client.Close(); closeErr != nil {
+			return closeErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,121 @@
+package shared
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func newTestRedisRateLimiter(t *testing.T, server *miniredis.Miniredis, algorithm Algorithm) *RedisRateLimiter {
+	t.Helper()
+
+	config := testConfig
+	config.Algorithm = algorithm
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	limiter, err := NewRedisRateLimiter(config, client, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = limiter.Close()
+		_ = client.Close()
+	})
+
+	return limiter
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	t.Run("should share a fixed window between replicas", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		first := newTestRedisRateLimiter(t, server, FixedWindow)
+		second := newTestRedisRateLimiter(t, server, FixedWindow)
+
+		// Act
+		allowed := allowN(first, "client", 6) + allowN(second, "client", 6)
+		retryAfter := second.Allow("client").RetryAfter
+
+		// Assert
+		assert.Equal(t, 10, allowed)
+		assert.Equal(t, time.Minute, retryAfter)
+	})
+
+	t.Run("should report the remaining quota of the fixed window", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		limiter := newTestRedisRateLimiter(t, server, FixedWindow)
+		allowN(limiter, "client", 3)
+
+		// Act
+		result := limiter.AllowN("client", 2)
+
+		// Assert
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 10, result.Limit)
+		assert.Equal(t, 5, result.Remaining)
+		assert.Equal(t, time.Minute, result.Reset)
+	})
+
+	t.Run("should reset the fixed window when it expires", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		limiter := newTestRedisRateLimiter(t, server, FixedWindow)
+		allowN(limiter, "client", 10)
+
+		// Act
+		server.FastForward(time.Minute)
+		allowed := allowN(limiter, "client", 15)
+
+		// Assert
+		assert.Equal(t, 10, allowed)
+	})
+
+	t.Run("should refill the token bucket using the redis time", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		server.SetTime(now)
+		first := newTestRedisRateLimiter(t, server, TokenBucket)
+		second := newTestRedisRateLimiter(t, server, TokenBucket)
+		allowN(first, "client", 10)
+
+		// Act
+		retryAfter := second.Allow("client").RetryAfter
+		server.SetTime(now.Add(6 * time.Second))
+		allowed := allowN(second, "client", 2)
+
+		// Assert
+		assert.Equal(t, 6*time.Second, retryAfter)
+		assert.Equal(t, 1, allowed)
+	})
+
+	t.Run("should fall back to local limits when redis is unreachable", func(t *testing.T) {
+		// Arrange
+		server := miniredis.RunT(t)
+		limiter := newTestRedisRateLimiter(t, server, FixedWindow)
+		server.Close()
+
+		// Act
+		allowed := allowN(limiter, "client", 15)
+
+		// Assert
+		assert.Equal(t, 10, allowed)
+	})
+
+	t.Run("should reject algorithms without a script", func(t *testing.T) {
+		// Arrange
+		config := testConfig
+		config.Algorithm = SlidingWindowLog
+
+		// Act
+		_, err := NewRedisRateLimiter(config, nil, nil)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
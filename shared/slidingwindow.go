@@ -0,0 +1,229 @@
+package shared
+
+import (
+	"go.uber.org/zap"
+	"math"
+	"net/http"
+	"time"
+)
+
+// SlidingWindowLogRateLimiter keeps the timestamp of every accepted request and allows
+// at most RequestPerTimeFrame of them within any TimeFrame. It is exact, at the cost of
+// memory proportional to the limit.
+type SlidingWindowLogRateLimiter struct {
+	clients *bucketStore[[]time.Time]
+	limit   int
+	window  time.Duration
+	enabled bool
+	logger  *zap.SugaredLogger
+	clock   Clock
+}
+
+func NewSlidingWindowLogRateLimiter(config Config, logger *zap.SugaredLogger) *SlidingWindowLogRateLimiter {
+	limiter := &SlidingWindowLogRateLimiter{
+		limit:   config.RequestPerTimeFrame,
+		window:  config.TimeFrame,
+		enabled: config.Enabled,
+		logger:  logger,
+		clock:   systemClock{},
+	}
+	limiter.clients = newBucketStore(config.MaxKeys, config.TimeFrame, limiter.now, limiter.expired)
+
+	return limiter
+}
+
+func (limiter *SlidingWindowLogRateLimiter) Allow(key string) Result {
+	return limiter.AllowN(key, 1)
+}
+
+// AllowN records one timestamp per unit of cost.
+func (limiter *SlidingWindowLogRateLimiter) AllowN(key string, n int) Result {
+	if !limiter.enabled {
+		return Result{Allowed: true}
+	}
+
+	limiter.clients.Lock()
+	defer limiter.clients.Unlock()
+
+	now := limiter.clock.Now()
+	log := limiter.clients.get(key, func(log *[]time.Time) {})
+
+	*log = expire(*log, now.Add(-limiter.window))
+
+	result := Result{
+		Limit:  limiter.limit,
+		Window: limiter.window,
+	}
+
+	if excess := len(*log) + n - limiter.limit; excess > 0 {
+		result.RetryAfter = limiter.window
+		if excess <= len(*log) {
+			result.RetryAfter = (*log)[excess-1].Add(limiter.window).Sub(now)
+		}
+	} else {
+		for i := 0; i < n; i++ {
+			*log = append(*log, now)
+		}
+		result.Allowed = true
+	}
+
+	result.Remaining = max(limiter.limit-len(*log), 0)
+	if len(*log) > 0 {
+		result.Reset = (*log)[0].Add(limiter.window).Sub(now)
+	}
+
+	return result
+}
+
+func (limiter *SlidingWindowLogRateLimiter) Close() error {
+	return limiter.clients.Close()
+}
+
+func (limiter *SlidingWindowLogRateLimiter) now() time.Time {
+	return limiter.clock.Now()
+}
+
+// expired reports whether the client made no requests in the current window.
+func (limiter *SlidingWindowLogRateLimiter) expired(log *[]time.Time, now time.Time) bool {
+	return len(expire(*log, now.Add(-limiter.window))) == 0
+}
+
+func (limiter *SlidingWindowLogRateLimiter) RateLimiterMiddleware() func(http.Handler) http.Handler {
+	return rateLimiterMiddleware(limiter, limiter.enabled, limiter.logger)
+}
+
+// expire drops the timestamps that are not after the cutoff.
+func expire(log []time.Time, cutoff time.Time) []time.Time {
+	for i, timestamp := range log {
+		if timestamp.After(cutoff) {
+			return log[i:]
+		}
+	}
+
+	return log[:0]
+}
+
+type slidingWindowCounter struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+// SlidingWindowCounterRateLimiter approximates a sliding window by weighting the count of
+// the previous fixed window by how much of it still overlaps the sliding window. It uses
+// constant memory per client and smooths out the bursts of a fixed window.
+type SlidingWindowCounterRateLimiter struct {
+	clients *bucketStore[slidingWindowCounter]
+	limit   int
+	window  time.Duration
+	enabled bool
+	logger  *zap.SugaredLogger
+	clock   Clock
+}
+
+func NewSlidingWindowCounterRateLimiter(config Config, logger *zap.SugaredLogger) *SlidingWindowCounterRateLimiter {
+	limiter := &SlidingWindowCounterRateLimiter{
+		limit:   config.RequestPerTimeFrame,
+		window:  config.TimeFrame,
+		enabled: config.Enabled,
+		logger:  logger,
+		clock:   systemClock{},
+	}
+	limiter.clients = newBucketStore(config.MaxKeys, config.TimeFrame, limiter.now, limiter.expired)
+
+	return limiter
+}
+
+func (limiter *SlidingWindowCounterRateLimiter) Allow(key string) Result {
+	return limiter.AllowN(key, 1)
+}
+
+func (limiter *SlidingWindowCounterRateLimiter) AllowN(key string, n int) Result {
+	if !limiter.enabled {
+		return Result{Allowed: true}
+	}
+
+	limiter.clients.Lock()
+	defer limiter.clients.Unlock()
+
+	now := limiter.clock.Now()
+	counter := limiter.clients.get(key, func(counter *slidingWindowCounter) {
+		counter.start = now.Truncate(limiter.window)
+	})
+	limiter.advance(counter, now)
+
+	elapsed := now.Sub(counter.start)
+	overlap := 1 - float64(elapsed)/float64(limiter.window)
+	estimated := float64(counter.previous)*overlap + float64(counter.current)
+
+	result := Result{
+		Limit:  limiter.limit,
+		Window: limiter.window,
+		Reset:  limiter.window - elapsed,
+	}
+
+	if estimated+float64(n) > float64(limiter.limit) {
+		result.RetryAfter = limiter.retryAfter(counter, elapsed, n)
+	} else {
+		counter.current += n
+		estimated += float64(n)
+		result.Allowed = true
+	}
+
+	result.Remaining = max(limiter.limit-int(math.Ceil(estimated)), 0)
+
+	return result
+}
+
+// advance rolls the counter over to the window containing now.
+func (limiter *SlidingWindowCounterRateLimiter) advance(counter *slidingWindowCounter, now time.Time) {
+	start := now.Truncate(limiter.window)
+	switch start.Sub(counter.start) {
+	case 0:
+		return
+	case limiter.window:
+		counter.previous = counter.current
+	default:
+		counter.previous = 0
+	}
+	counter.start = start
+	counter.current = 0
+}
+
+// retryAfter returns how long until the weighted count has decayed enough to accept a
+// request costing n units.
+func (limiter *SlidingWindowCounterRateLimiter) retryAfter(counter *slidingWindowCounter, elapsed time.Duration, n int) time.Duration {
+	remaining := limiter.window - elapsed
+	if n > limiter.limit {
+		return remaining + limiter.window
+	}
+	if counter.current+n > limiter.limit {
+		free := float64(limiter.limit-n) / float64(counter.current)
+		return remaining + time.Duration((1-free)*float64(limiter.window))
+	}
+	if counter.previous == 0 {
+		return remaining
+	}
+
+	free := float64(limiter.limit-counter.current-n) / float64(counter.previous)
+	wait := time.Duration((1-free)*float64(limiter.window)) - elapsed
+
+	return min(max(wait, time.Nanosecond), remaining)
+}
+
+func (limiter *SlidingWindowCounterRateLimiter) Close() error {
+	return limiter.clients.Close()
+}
+
+func (limiter *SlidingWindowCounterRateLimiter) now() time.Time {
+	return limiter.clock.Now()
+}
+
+// expired reports whether the client made no requests in the current or the previous window.
+func (limiter *SlidingWindowCounterRateLimiter) expired(counter *slidingWindowCounter, now time.Time) bool {
+	return counter.start.Before(now.Truncate(limiter.window).Add(-limiter.window))
+}
+
+func (limiter *SlidingWindowCounterRateLimiter) RateLimiterMiddleware() func(http.Handler) http.Handler {
+	return rateLimiterMiddleware(limiter, limiter.enabled, limiter.logger)
+}
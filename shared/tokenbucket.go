@@ -0,0 +1,97 @@
+package shared
+
+import (
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// TokenBucketRateLimiter allows bursts of up to RequestPerTimeFrame requests and refills
+// tokens continuously at RequestPerTimeFrame per TimeFrame.
+type TokenBucketRateLimiter struct {
+	clients  *bucketStore[tokenBucket]
+	capacity float64
+	refill   time.Duration
+	enabled  bool
+	logger   *zap.SugaredLogger
+	clock    Clock
+}
+
+func NewTokenBucketRateLimiter(config Config, logger *zap.SugaredLogger) *TokenBucketRateLimiter {
+	limiter := &TokenBucketRateLimiter{
+		capacity: float64(config.RequestPerTimeFrame),
+		refill:   config.TimeFrame / time.Duration(max(config.RequestPerTimeFrame, 1)),
+		enabled:  config.Enabled,
+		logger:   logger,
+		clock:    systemClock{},
+	}
+	limiter.clients = newBucketStore(config.MaxKeys, config.TimeFrame, limiter.now, limiter.expired)
+
+	return limiter
+}
+
+func (limiter *TokenBucketRateLimiter) Allow(key string) Result {
+	return limiter.AllowN(key, 1)
+}
+
+func (limiter *TokenBucketRateLimiter) AllowN(key string, n int) Result {
+	if !limiter.enabled {
+		return Result{Allowed: true}
+	}
+
+	limiter.clients.Lock()
+	defer limiter.clients.Unlock()
+
+	now := limiter.clock.Now()
+	bucket := limiter.clients.get(key, func(bucket *tokenBucket) {
+		bucket.tokens = limiter.capacity
+		bucket.updated = now
+	})
+
+	bucket.tokens = limiter.tokensAt(bucket, now)
+	bucket.updated = now
+
+	result := Result{
+		Limit:  int(limiter.capacity),
+		Window: limiter.refill * time.Duration(limiter.capacity),
+	}
+
+	if bucket.tokens < float64(n) {
+		result.RetryAfter = time.Duration((float64(n) - bucket.tokens) * float64(limiter.refill))
+	} else {
+		bucket.tokens -= float64(n)
+		result.Allowed = true
+	}
+
+	result.Remaining = int(bucket.tokens)
+	result.Reset = time.Duration((limiter.capacity - bucket.tokens) * float64(limiter.refill))
+
+	return result
+}
+
+func (limiter *TokenBucketRateLimiter) Close() error {
+	return limiter.clients.Close()
+}
+
+func (limiter *TokenBucketRateLimiter) tokensAt(bucket *tokenBucket, now time.Time) float64 {
+	refilled := float64(now.Sub(bucket.updated)) / float64(limiter.refill)
+	return min(limiter.capacity, bucket.tokens+refilled)
+}
+
+func (limiter *TokenBucketRateLimiter) now() time.Time {
+	return limiter.clock.Now()
+}
+
+// expired reports whether the bucket has refilled completely, as it then behaves like a new one.
+func (limiter *TokenBucketRateLimiter) expired(bucket *tokenBucket, now time.Time) bool {
+	return limiter.tokensAt(bucket, now) >= limiter.capacity
+}
+
+func (limiter *TokenBucketRateLimiter) RateLimiterMiddleware() func(http.Handler) http.Handler {
+	return rateLimiterMiddleware(limiter, limiter.enabled, limiter.logger)
+}
@@ -0,0 +1,139 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+)
+
+type TracingExporter string
+
+const (
+	TracingExporterNone   TracingExporter = "none"
+	TracingExporterStdout TracingExporter = "stdout"
+	TracingExporterOTLP   TracingExporter = "otlp"
+)
+
+// tracerName identifies the spans created by this package.
+const tracerName = "github.com/dawidpereira/online-store-go/shared"
+
+type TracingConfig struct {
+	Exporter TracingExporter `env:"EXPORTER" default:"none"`
+	// Endpoint is the host:port of the OTLP HTTP collector. When empty the exporter reads
+	// the standard OTEL_EXPORTER_OTLP_* variables.
+	Endpoint    string  `env:"OTLP_ENDPOINT"`
+	Insecure    bool    `env:"OTLP_INSECURE" default:"false"`
+	SampleRatio float64 `env:"SAMPLE_RATIO" default:"1"`
+}
+
+// Propagator reads and writes W3C traceparent, tracestate and baggage headers.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// NewTracerProvider creates the tracer provider of the service and installs it, with the
+// W3C propagator, as the global one. Spans are created even without an exporter, so trace
+// IDs still reach the logs and downstream services. The caller must Shutdown the provider
+// to flush the spans.
+func NewTracerProvider(ctx context.Context, config TracingConfig, service, version string) (*sdktrace.TracerProvider, error) {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(service),
+			semconv.ServiceVersion(version),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	}
+
+	switch config.Exporter {
+	case TracingExporterNone, "":
+	case TracingExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case TracingExporterOTLP:
+		var exporterOptions []otlptracehttp.Option
+		if config.Endpoint != "" {
+			exporterOptions = append(exporterOptions, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err := otlptracehttp.New(ctx, exporterOptions...)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(Propagator)
+
+	return provider, nil
+}
+
+// TracingMiddleware starts a server span for every request, continuing the trace of the
+// caller. The span is named after the chi route pattern once routing has finished, so
+// it must be installed on a chi router.
+func TracingMiddleware(provider trace.TracerProvider) func(http.Handler) http.Handler {
+	tracer := provider.Tracer(tracerName)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			))
+			defer span.End()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				span.SetName(r.Method + " " + rctx.RoutePattern())
+				span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+			}
+		})
+	}
+}
+
+// TraceLogger returns the logger with the trace and span IDs of ctx, so log lines can be
+// found from a trace and the other way round.
+func TraceLogger(ctx context.Context, logger *zap.SugaredLogger) *zap.SugaredLogger {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return logger
+	}
+
+	return logger.With("trace_id", spanContext.TraceID().String(), "span_id", spanContext.SpanID().String())
+}
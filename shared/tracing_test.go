@@ -0,0 +1,105 @@
+package shared
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestTracerProvider(t *testing.T) (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+
+	return provider, recorder
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	t.Run("should name the span after the route pattern", func(t *testing.T) {
+		// Arrange
+		provider, recorder := newTestTracerProvider(t)
+		r := chi.NewRouter()
+		r.Use(TracingMiddleware(provider))
+		r.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		// Act
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/1", nil))
+
+		// Assert
+		spans := recorder.Ended()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, "GET /products/{id}", spans[0].Name())
+		assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+		assert.Equal(t, "Error", spans[0].Status().Code.String())
+	})
+
+	t.Run("should continue the trace of the traceparent header", func(t *testing.T) {
+		// Arrange
+		provider, recorder := newTestTracerProvider(t)
+		handler := TracingMiddleware(provider)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		// Assert
+		spans := recorder.Ended()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	})
+}
+
+func TestTraceLogger(t *testing.T) {
+	t.Run("should add the trace and span ids", func(t *testing.T) {
+		// Arrange
+		provider, _ := newTestTracerProvider(t)
+		core, logs := observer.New(zap.InfoLevel)
+		ctx, span := provider.Tracer("test").Start(context.Background(), "operation")
+		defer span.End()
+
+		// Act
+		TraceLogger(ctx, zap.New(core).Sugar()).Info("message")
+
+		// Assert
+		fields := logs.All()[0].ContextMap()
+		assert.Equal(t, span.SpanContext().TraceID().String(), fields["trace_id"])
+		assert.Equal(t, span.SpanContext().SpanID().String(), fields["span_id"])
+	})
+
+	t.Run("should keep the logger without a span", func(t *testing.T) {
+		// Arrange
+		logger := zap.NewNop().Sugar()
+
+		// Act
+		traced := TraceLogger(context.Background(), logger)
+
+		// Assert
+		assert.Same(t, logger, traced)
+	})
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	t.Run("should reject unknown exporters", func(t *testing.T) {
+		// Act
+		_, err := NewTracerProvider(context.Background(), TracingConfig{Exporter: "jaeger"}, "test", "1.0")
+
+		// Assert
+		assert.Error(t, err)
+	})
+}